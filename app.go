@@ -1,133 +1,671 @@
 package main
 
 import (
-	"encoding/xml"
+	"bufio"
+	"errors"
 	"fmt"
+	"io"
 	"log"
-	"os"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 
+	"github.com/chuhlomin/instapaper2rss/pkg/atomicfile"
 	"github.com/chuhlomin/instapaper2rss/pkg/instapaper"
+	"github.com/chuhlomin/instapaper2rss/pkg/precompress"
 	"github.com/chuhlomin/instapaper2rss/pkg/structs"
 )
 
 type Instapaper interface {
 	GetBookmarks(params map[string]string) ([]instapaper.Item, error)
 	GetBookmarkText(bookmarkID int) (string, error)
+	GetFolders() ([]instapaper.Item, error)
+	RequestCount() int
 }
 
 type Storage interface {
 	GetBookmarks() ([]structs.Bookmark, error)
 	WriteBookmark(bookmark *structs.Bookmark) error
+	WriteRun(run *structs.Run) error
 }
 
+// MetaStorage is implemented by storages that keep metadata,
+// used to remember the content hashes of written files between runs.
+type MetaStorage interface {
+	GetMeta(key string) (string, error)
+	SetMeta(key, value string) error
+}
+
+// fileHashPrefix prefixes the metadata keys of file content hashes.
+const fileHashPrefix = "file_hash:"
+
 type FeedBuilder interface {
 	Build(bookmarks []structs.Bookmark) ([]byte, error)
 }
 
+// WriterBuilder is implemented by feed builders that can write
+// the feed as it is encoded, without holding it in memory.
+type WriterBuilder interface {
+	BuildTo(w io.Writer, bookmarks []structs.Bookmark) error
+}
+
+// FilesBuilder is implemented by feed builders that split
+// the feed into several documents, such as feed archives.
+type FilesBuilder interface {
+	BuildFiles(path string, bookmarks []structs.Bookmark) ([]structs.File, error)
+}
+
+// Pruner is implemented by files builders that remove the files
+// they wrote in previous runs but not in this one, such as the pages
+// of deleted bookmarks. Prune returns the paths of removed files.
+type Pruner interface {
+	Prune(path string, keep []string) ([]string, error)
+}
+
+// Processor transforms a new bookmark after its text is fetched
+// and before it is stored, e.g. to sanitize the article HTML.
+type Processor interface {
+	Process(b *structs.Bookmark) error
+}
+
+// FilesProcessor is implemented by processors that write files,
+// such as downloaded images, to be published with the feeds.
+type FilesProcessor interface {
+	Files() []string
+}
+
+// defaultFolder is the folder bookmarks/list returns when no folder_id is given.
+const defaultFolder = "unread"
+
+// Output is a feed file written by a FeedBuilder.
+// With a Filter, only matching bookmarks are included.
+// With Validate, files that fail validation are not written.
+// With Encodings, compressed copies are written next to each file,
+// e.g. feed.xml.gz.
+type Output struct {
+	Builder   FeedBuilder
+	Path      string
+	Filter    func(structs.Bookmark) bool
+	Validate  func(r io.Reader) error
+	Encodings []precompress.Encoding
+}
+
 type App struct {
-	instapaper  Instapaper
-	storage     Storage
-	feedBuilder FeedBuilder
+	instapaper Instapaper
+	storage    Storage
+	outputs    []Output
+	folders    []string
+	processors []Processor
+	now        func() time.Time
+}
+
+type Option func(*App)
+
+// WithFolders makes the app sync bookmarks from the folders,
+// given by title, in addition to the default one.
+func WithFolders(folders ...string) Option {
+	return func(a *App) {
+		a.folders = append(a.folders, folders...)
+	}
+}
+
+// WithProcessors applies the processors, in order, to new bookmarks.
+func WithProcessors(processors ...Processor) Option {
+	return func(a *App) {
+		a.processors = append(a.processors, processors...)
+	}
 }
 
 func NewApp(
 	instapaper Instapaper,
 	storage Storage,
-	feedBuilder FeedBuilder,
+	outputs []Output,
+	options ...Option,
 ) *App {
-	return &App{
-		instapaper:  instapaper,
-		storage:     storage,
-		feedBuilder: feedBuilder,
+	app := &App{
+		instapaper: instapaper,
+		storage:    storage,
+		outputs:    outputs,
+		now:        time.Now,
+	}
+
+	for _, option := range options {
+		option(app)
 	}
+
+	return app
 }
 
-func (a *App) Run(feedPath string) (int, error) {
-	existingBookmarks, err := a.storage.GetBookmarks()
+// Run syncs new bookmarks, rebuilds the feeds from all stored bookmarks
+// and records the run in storage.
+func (a *App) Run() (structs.Run, error) {
+	return a.record("run", func(run *structs.Run) error {
+		bookmarks, err := a.sync(run)
+		if err != nil {
+			return err
+		}
+		return a.build(run, bookmarks)
+	})
+}
+
+// Sync stores new and changed bookmarks from Instapaper without
+// building the feeds, and records the run in storage.
+func (a *App) Sync() (structs.Run, error) {
+	return a.record("sync", func(run *structs.Run) error {
+		_, err := a.sync(run)
+		return err
+	})
+}
+
+// Build rebuilds the feeds from stored bookmarks without calling
+// Instapaper, so the app may have no Instapaper client,
+// and records the run in storage.
+func (a *App) Build() (structs.Run, error) {
+	return a.record("build", func(run *structs.Run) error {
+		bookmarks, err := a.storage.GetBookmarks()
+		if err != nil {
+			return fmt.Errorf("error getting bookmarks: %w", err)
+		}
+		return a.build(run, bookmarks)
+	})
+}
+
+// record runs f and saves the run with its outcome in storage.
+func (a *App) record(command string, f func(run *structs.Run) error) (structs.Run, error) {
+	run := structs.Run{Command: command, Start: a.now()}
+
+	err := f(&run)
+
+	run.End = a.now()
+	if a.instapaper != nil {
+		run.Requests = a.instapaper.RequestCount()
+	}
 	if err != nil {
-		return 0, fmt.Errorf("error getting existing bookmarks: %w", err)
+		run.Failures++
+		run.Error = err.Error()
+	}
+
+	if errRun := a.storage.WriteRun(&run); errRun != nil {
+		err = errors.Join(err, fmt.Errorf("error saving run: %w", errRun))
 	}
 
-	params := map[string]string{}
-	if len(existingBookmarks) > 0 {
-		params["have"] = concatBookmarksIDs(existingBookmarks)
+	return run, err
+}
+
+// sync stores new and changed bookmarks and returns all bookmarks.
+func (a *App) sync(run *structs.Run) ([]structs.Bookmark, error) {
+	existingBookmarks, err := a.storage.GetBookmarks()
+	if err != nil {
+		return nil, fmt.Errorf("error getting existing bookmarks: %w", err)
 	}
 
-	items, err := a.instapaper.GetBookmarks(params)
+	folders, err := a.listFolders()
 	if err != nil {
-		return 0, fmt.Errorf("error getting bookmarks: %w", err)
+		return nil, fmt.Errorf("error getting folders: %w", err)
+	}
+
+	existing := make(map[int]int, len(existingBookmarks))
+	for i, b := range existingBookmarks {
+		existing[b.ID] = i
 	}
 
 	var bookmarks []structs.Bookmark
-	for _, item := range items {
-		switch item.Type {
-		case "bookmark":
-			bookmarks = append(bookmarks, structs.Bookmark{
-				ID:    item.BookmarkID,
-				Title: item.Title,
-				URL:   item.URL,
-				Time:  item.Time,
-				Hash:  item.Hash,
-			})
+	seen := map[int]bool{}
+	for _, f := range folders {
+		params := map[string]string{}
+		if have := formatHave(f.known(existingBookmarks)); have != "" {
+			params["have"] = have
+		}
+		if f.id != "" {
+			params["folder_id"] = f.id
+		}
+
+		items, err := a.instapaper.GetBookmarks(params)
+		if err != nil {
+			if f.id != "" {
+				return nil, fmt.Errorf("error getting bookmarks from folder %q: %w", f.name, err)
+			}
+			return nil, fmt.Errorf("error getting bookmarks: %w", err)
+		}
+
+		// highlights are listed after the bookmarks they belong to
+		highlights := groupHighlights(items)
+
+		// starred is a flag, not a location: bookmarks listed only as starred
+		// keep their stored folders, or have none when they are new
+		isStarred := f.id == "starred"
+
+		for _, item := range items {
+			switch item.Type {
+			case "bookmark":
+				// "starred" lists bookmarks from other folders too
+				if seen[item.BookmarkID] {
+					continue
+				}
+				seen[item.BookmarkID] = true
+				run.ItemsListed++
+
+				// Instapaper lists a known bookmark again when its hash changes,
+				// e.g. after it was starred or read further: update it in place
+				if i, ok := existing[item.BookmarkID]; ok {
+					b := &existingBookmarks[i]
+					name := f.name
+					if isStarred {
+						name = b.Folder
+					}
+					applyItem(b, item, name, a.now())
+					b.Highlights = highlights[item.BookmarkID]
+					if err := a.storage.WriteBookmark(b); err != nil {
+						return nil, fmt.Errorf("error updating bookmark %d: %w", b.ID, err)
+					}
+					run.UpdatedBookmarks++
+					continue
+				}
+
+				var b structs.Bookmark
+				name := f.name
+				if isStarred {
+					name = ""
+				}
+				applyItem(&b, item, name, a.now())
+				b.Highlights = highlights[item.BookmarkID]
+				bookmarks = append(bookmarks, b)
+			}
 		}
 	}
 
 	for i, b := range bookmarks {
 		text, err := a.instapaper.GetBookmarkText(b.ID)
 		if err != nil {
-			return 0, fmt.Errorf("error getting bookmark %d text: %w", b.ID, err)
+			return nil, fmt.Errorf("error getting bookmark %d text: %w", b.ID, err)
 		}
+		run.TextsFetched++
 
 		b.Text = text
+		for _, p := range a.processors {
+			if err := p.Process(&b); err != nil {
+				return nil, fmt.Errorf("error processing bookmark %d: %w", b.ID, err)
+			}
+		}
+
 		bookmarks[i] = b
 		if err := a.storage.WriteBookmark(&b); err != nil {
-			return 0, fmt.Errorf("error writing bookmark %d text: %w", b.ID, err)
+			return nil, fmt.Errorf("error writing bookmark %d text: %w", b.ID, err)
 		}
 	}
 
-	if len(bookmarks) == 0 {
+	for _, p := range a.processors {
+		if fp, ok := p.(FilesProcessor); ok {
+			run.Files = append(run.Files, fp.Files()...)
+		}
+	}
+
+	if len(bookmarks) == 0 && run.UpdatedBookmarks == 0 {
 		log.Println("No new bookmarks")
-		return 0, nil
+	}
+
+	run.NewBookmarks = len(bookmarks)
+	return append(existingBookmarks, bookmarks...), nil
+}
+
+// build writes the outputs for bookmarks.
+func (a *App) build(run *structs.Run, bookmarks []structs.Bookmark) error {
+	// build and validate everything into temporary files first,
+	// so an invalid feed leaves all previously written files in place
+	var files []*atomicfile.File
+	defer func() {
+		for _, f := range files {
+			f.Abort()
+		}
+	}()
+
+	// paths of the files written for each output, compressed copies included
+	written := make([][]string, len(a.outputs))
+	for i, output := range a.outputs {
+		first := len(files)
+		built, err := buildFiles(output, filterBookmarks(bookmarks, output.Filter))
+		files = append(files, built...)
+		if err != nil {
+			return fmt.Errorf("error building feed %s: %w", output.Path, err)
+		}
+
+		for _, f := range built {
+			run.FeedSize += int(f.Size())
+
+			if output.Validate != nil {
+				if err := validateFile(f, output.Validate); err != nil {
+					return fmt.Errorf("invalid feed %s: %w", f.Path(), err)
+				}
+			}
+
+			for _, e := range output.Encodings {
+				c, err := compressFile(f, e)
+				if err != nil {
+					return fmt.Errorf("error compressing feed %s: %w", f.Path(), err)
+				}
+				files = append(files, c)
+			}
+		}
+
+		for _, f := range files[first:] {
+			written[i] = append(written[i], f.Path())
+		}
+	}
+
+	for _, f := range files {
+		changed, err := a.saveFile(f)
+		if err != nil {
+			return fmt.Errorf("error saving feed %s: %w", f.Path(), err)
+		}
+		if changed {
+			run.Files = append(run.Files, f.Path())
+			run.FeedChanged = true
+		}
+	}
+
+	// only prune once every output is saved,
+	// so a failed run never leaves pages linking to removed ones
+	for i, output := range a.outputs {
+		p, ok := output.Builder.(Pruner)
+		if !ok {
+			continue
+		}
+
+		removed, err := p.Prune(output.Path, written[i])
+		for _, path := range removed {
+			log.Printf("Removed %s", path)
+			run.FeedChanged = true
+			if err := a.forgetFile(path); err != nil {
+				return err
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("error pruning %s: %w", output.Path, err)
+		}
+	}
+
+	return nil
+}
+
+// forgetFile clears the content hash of a removed file,
+// so it is reported as changed when it is written again.
+func (a *App) forgetFile(path string) error {
+	ms, ok := a.storage.(MetaStorage)
+	if !ok {
+		return nil
+	}
+
+	if err := ms.SetMeta(fileHashPrefix+path, ""); err != nil {
+		return fmt.Errorf("error clearing content hash: %w", err)
+	}
+	return nil
+}
 
-		// bookmarks = existingBookmarks
-		// log.Printf("Using existing %d bookmarks", len(bookmarks))
+// saveFile commits the file unless it has the same content, and reports
+// whether the content changed since the previous run. With MetaStorage,
+// the content hash is compared with the one of the previous run,
+// so a file missing locally is written but not reported as changed.
+func (a *App) saveFile(f *atomicfile.File) (bool, error) {
+	written := false
+	if !f.Unchanged() {
+		if err := f.Commit(); err != nil {
+			return false, err
+		}
+		written = true
 	}
 
-	newBookmarksCount := len(bookmarks)
-	bookmarks = append(existingBookmarks, bookmarks...)
+	ms, ok := a.storage.(MetaStorage)
+	if !ok {
+		return written, nil
+	}
 
-	b, err := a.feedBuilder.Build(bookmarks)
+	key := fileHashPrefix + f.Path()
+	previous, err := ms.GetMeta(key)
 	if err != nil {
-		return 0, fmt.Errorf("error building feed: %w", err)
+		return false, fmt.Errorf("error getting content hash: %w", err)
 	}
 
-	return newBookmarksCount, saveFeed(b, feedPath)
+	hash := f.Sum()
+	if hash == previous {
+		return false, nil
+	}
+
+	if err := ms.SetMeta(key, hash); err != nil {
+		return false, fmt.Errorf("error saving content hash: %w", err)
+	}
+	return true, nil
 }
 
-func concatBookmarksIDs(bookmarks []structs.Bookmark) string {
-	result := make([]string, len(bookmarks))
-	for i, b := range bookmarks {
-		result[i] = strconv.Itoa(b.ID)
+type folder struct {
+	name string
+	id   string // folder_id parameter, empty for the default folder
+}
+
+// known returns the bookmarks stored as in the folder. A bookmark moved
+// into the folder since it was synced is left out, so Instapaper lists it
+// and its folder is updated. Starred bookmarks stay in their folders,
+// so all bookmarks are known when listing "starred".
+func (f folder) known(bookmarks []structs.Bookmark) []structs.Bookmark {
+	if f.id == "starred" {
+		return bookmarks
 	}
-	return strings.Join(result, ",")
+
+	var result []structs.Bookmark
+	for _, b := range bookmarks {
+		// bookmarks stored before folders were synced are in the default one
+		if b.Folder == f.name || b.Folder == "" && f.name == defaultFolder {
+			result = append(result, b)
+		}
+	}
+	return result
+}
+
+// listFolders returns the default folder followed by the folders
+// configured with WithFolders, resolving folder titles to IDs.
+func (a *App) listFolders() ([]folder, error) {
+	folders := []folder{{name: defaultFolder}}
+	if len(a.folders) == 0 {
+		return folders, nil
+	}
+
+	var userFolders []instapaper.Item
+	starred := false
+	for _, name := range a.folders {
+		switch name {
+		case defaultFolder:
+			continue
+		case "starred":
+			starred = true
+			continue
+		case "archive":
+			folders = append(folders, folder{name: name, id: name})
+			continue
+		}
+
+		if userFolders == nil {
+			items, err := a.instapaper.GetFolders()
+			if err != nil {
+				return nil, err
+			}
+			userFolders = items
+		}
+
+		found := false
+		for _, item := range userFolders {
+			if item.Type == "folder" && strings.EqualFold(item.Title, name) {
+				folders = append(folders, folder{name: item.Title, id: strconv.Itoa(item.FolderID)})
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("folder %q not found", name)
+		}
+	}
+
+	// starred bookmarks are in other folders, list them last
+	// so they get the folders they are in
+	if starred {
+		folders = append(folders, folder{name: "starred", id: "starred"})
+	}
+
+	return folders, nil
+}
+
+// inFolder reports whether the bookmark is in the folder.
+// "starred" has the starred bookmarks of all folders.
+func inFolder(b structs.Bookmark, name string) bool {
+	if strings.EqualFold(name, "starred") {
+		return b.Starred
+	}
+	return strings.EqualFold(b.Folder, name)
+}
+
+func filterBookmarks(bookmarks []structs.Bookmark, filter func(structs.Bookmark) bool) []structs.Bookmark {
+	if filter == nil {
+		return bookmarks
+	}
+
+	var result []structs.Bookmark
+	for _, b := range bookmarks {
+		if filter(b) {
+			result = append(result, b)
+		}
+	}
+	return result
+}
+
+// buildFiles writes the output files into temporary files,
+// streaming them from builders implementing WriterBuilder.
+// On error, the returned files must still be aborted.
+func buildFiles(output Output, bookmarks []structs.Bookmark) ([]*atomicfile.File, error) {
+	var built []structs.File
+	switch b := output.Builder.(type) {
+	case FilesBuilder:
+		var err error
+		if built, err = b.BuildFiles(output.Path, bookmarks); err != nil {
+			return nil, err
+		}
+
+	case WriterBuilder:
+		f, err := atomicfile.Create(output.Path)
+		if err != nil {
+			return nil, err
+		}
+		return []*atomicfile.File{f}, b.BuildTo(f, bookmarks)
+
+	default:
+		data, err := b.Build(bookmarks)
+		if err != nil {
+			return nil, err
+		}
+		built = []structs.File{{Path: output.Path, Data: data}}
+	}
+
+	files := make([]*atomicfile.File, 0, len(built))
+	for _, bf := range built {
+		f, err := atomicfile.Create(bf.Path)
+		if err != nil {
+			return files, err
+		}
+		files = append(files, f)
+
+		if _, err := f.Write(bf.Data); err != nil {
+			return files, err
+		}
+	}
+
+	return files, nil
+}
+
+func validateFile(f *atomicfile.File, validate func(r io.Reader) error) error {
+	r, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	return validate(bufio.NewReader(r))
 }
 
-func saveFeed(feed []byte, filename string) error {
-	file, err := os.Create(filename)
+// compressFile writes the compressed copy of f next to it.
+func compressFile(f *atomicfile.File, e precompress.Encoding) (*atomicfile.File, error) {
+	r, err := f.Open()
 	if err != nil {
-		return fmt.Errorf("error creating file: %w", err)
+		return nil, err
 	}
-	defer file.Close()
+	defer r.Close()
 
-	if _, err := file.WriteString(xml.Header); err != nil {
-		return fmt.Errorf("error writing XML header: %w", err)
+	c, err := atomicfile.Create(f.Path() + e.Ext)
+	if err != nil {
+		return nil, err
 	}
 
-	if _, err := file.Write(feed); err != nil {
-		return fmt.Errorf("error writing feed: %w", err)
+	if err := e.Compress(c, bufio.NewReader(r)); err != nil {
+		c.Abort()
+		return nil, err
 	}
 
-	return nil
+	return c, nil
+}
+
+// applyItem copies bookmark fields returned by the Instapaper API to b,
+// leaving the text and other locally stored fields intact.
+func applyItem(b *structs.Bookmark, item instapaper.Item, folder string, now time.Time) {
+	b.ID = item.BookmarkID
+	b.Title = item.Title
+	b.URL = item.URL
+	b.Time = item.Time
+	b.Hash = item.Hash
+	b.Description = item.Description
+	b.Folder = folder
+	b.Starred = item.Starred == "1"
+	b.Progress = item.Progress
+	b.ProgressTime = item.ProgressTimestamp
+	b.SyncedAt = now.Unix()
+
+	b.Tags = nil
+	for _, tag := range item.Tags {
+		b.Tags = append(b.Tags, tag.Name)
+	}
+}
+
+// groupHighlights returns highlights in items by bookmark ID,
+// in the order they appear in the article.
+func groupHighlights(items []instapaper.Item) map[int][]structs.Highlight {
+	result := map[int][]structs.Highlight{}
+	for _, item := range items {
+		if item.Type != "highlight" {
+			continue
+		}
+		result[item.BookmarkID] = append(result[item.BookmarkID], structs.Highlight{
+			ID:       item.HighlightID,
+			Text:     item.Text,
+			Time:     item.Time,
+			Position: item.Position,
+		})
+	}
+
+	for _, h := range result {
+		sort.SliceStable(h, func(i, j int) bool {
+			return h[i].Position < h[j].Position
+		})
+	}
+
+	return result
+}
+
+// formatHave formats the "have" parameter of bookmarks/list as "id:hash" pairs,
+// so Instapaper skips known bookmarks unless they changed.
+// Bookmarks without a hash are left out to be listed again.
+func formatHave(bookmarks []structs.Bookmark) string {
+	result := make([]string, 0, len(bookmarks))
+	for _, b := range bookmarks {
+		if b.Hash == "" {
+			continue
+		}
+		result = append(result, strconv.Itoa(b.ID)+":"+b.Hash)
+	}
+	return strings.Join(result, ",")
 }
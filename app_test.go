@@ -1,13 +1,24 @@
 package main
 
 import (
+	"compress/gzip"
 	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/andybalholm/brotli"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 
 	"github.com/chuhlomin/instapaper2rss/pkg/instapaper"
+	"github.com/chuhlomin/instapaper2rss/pkg/precompress"
+	"github.com/chuhlomin/instapaper2rss/pkg/sanitize"
+	"github.com/chuhlomin/instapaper2rss/pkg/site"
 	"github.com/chuhlomin/instapaper2rss/pkg/structs"
 )
 
@@ -26,6 +37,16 @@ func (m *MockInstapaper) GetBookmarkText(bookmarkID int) (string, error) {
 	return args.String(0), args.Error(1)
 }
 
+func (m *MockInstapaper) GetFolders() ([]instapaper.Item, error) {
+	args := m.Called()
+	return args.Get(0).([]instapaper.Item), args.Error(1)
+}
+
+func (m *MockInstapaper) RequestCount() int {
+	args := m.Called()
+	return args.Int(0)
+}
+
 // Mock for Storage interface
 type MockStorage struct {
 	mock.Mock
@@ -41,6 +62,11 @@ func (m *MockStorage) WriteBookmark(bookmark *structs.Bookmark) error {
 	return args.Error(0)
 }
 
+func (m *MockStorage) WriteRun(run *structs.Run) error {
+	args := m.Called(run)
+	return args.Error(0)
+}
+
 // Mock for FeedBuilder interface
 type MockFeedBuilder struct {
 	mock.Mock
@@ -56,6 +82,7 @@ func TestApp_Run(t *testing.T) {
 		name          string
 		setupMocks    func(*MockInstapaper, *MockStorage, *MockFeedBuilder)
 		expectedError string
+		expectedRun   structs.Run
 	}{
 		{
 			name: "successful run, empty storage",
@@ -82,16 +109,56 @@ func TestApp_Run(t *testing.T) {
 				})).Return(nil)
 				mf.On("Build", []structs.Bookmark{
 					{
-						ID:    1,
-						Title: "Test Bookmark",
-						URL:   "https://example.com",
-						Hash:  "abc123",
-						Time:  1739202544,
-						Text:  "Test content",
+						ID:       1,
+						Title:    "Test Bookmark",
+						URL:      "https://example.com",
+						Hash:     "abc123",
+						Time:     1739202544,
+						Text:     "Test content",
+						Folder:   "unread",
+						SyncedAt: 1740000000,
 					},
 				}).Return([]byte("feed"), nil)
 			},
 			expectedError: "",
+			expectedRun:   structs.Run{ItemsListed: 1, TextsFetched: 1, NewBookmarks: 1, FeedSize: 4, Requests: 2},
+		},
+		{
+			name: "bookmark with highlights",
+			setupMocks: func(mi *MockInstapaper, ms *MockStorage, mf *MockFeedBuilder) {
+				ms.On("GetBookmarks").Return([]structs.Bookmark{}, nil)
+				mi.On("GetBookmarks", map[string]string{}).Return([]instapaper.Item{
+					{
+						BookmarkID: 1,
+						Type:       "bookmark",
+						Title:      "Test Bookmark",
+						URL:        "https://example.com",
+						Hash:       "abc123",
+						Time:       1739202544,
+					},
+					{Type: "highlight", HighlightID: 11, BookmarkID: 1, Text: "Second", Time: 1739300000, Position: 1},
+					{Type: "highlight", HighlightID: 10, BookmarkID: 1, Text: "First", Time: 1739300001, Position: 0},
+				}, nil)
+				mi.On("GetBookmarkText", 1).Return("Test content", nil)
+				ms.On("WriteBookmark", mock.Anything).Return(nil)
+				mf.On("Build", []structs.Bookmark{
+					{
+						ID:       1,
+						Title:    "Test Bookmark",
+						URL:      "https://example.com",
+						Hash:     "abc123",
+						Time:     1739202544,
+						Text:     "Test content",
+						Folder:   "unread",
+						SyncedAt: 1740000000,
+						Highlights: []structs.Highlight{
+							{ID: 10, Text: "First", Time: 1739300001, Position: 0},
+							{ID: 11, Text: "Second", Time: 1739300000, Position: 1},
+						},
+					},
+				}).Return([]byte("feed"), nil)
+			},
+			expectedRun: structs.Run{ItemsListed: 1, TextsFetched: 1, NewBookmarks: 1, FeedSize: 4, Requests: 2},
 		},
 		{
 			name: "successful run, storage has bookmarks",
@@ -106,7 +173,7 @@ func TestApp_Run(t *testing.T) {
 						Time:  1739202544,
 					},
 				}, nil)
-				mi.On("GetBookmarks", map[string]string{"have": "1"}).Return([]instapaper.Item{
+				mi.On("GetBookmarks", map[string]string{"have": "1:abc123"}).Return([]instapaper.Item{
 					{
 						Type:     "user",
 						UserID:   100,
@@ -135,16 +202,88 @@ func TestApp_Run(t *testing.T) {
 						Text:  "Test content",
 					},
 					{
-						ID:    2,
-						Title: "Test Bookmark 2",
-						URL:   "https://example.com/2",
-						Hash:  "abc456",
-						Time:  1739202544,
-						Text:  "Test content 2",
+						ID:       2,
+						Title:    "Test Bookmark 2",
+						URL:      "https://example.com/2",
+						Hash:     "abc456",
+						Time:     1739202544,
+						Text:     "Test content 2",
+						Folder:   "unread",
+						SyncedAt: 1740000000,
+					},
+				}).Return([]byte("feed"), nil)
+			},
+			expectedError: "",
+			expectedRun:   structs.Run{ItemsListed: 1, TextsFetched: 1, NewBookmarks: 1, FeedSize: 4, Requests: 2},
+		},
+		{
+			name: "successful run, known bookmark changed",
+			setupMocks: func(mi *MockInstapaper, ms *MockStorage, mf *MockFeedBuilder) {
+				ms.On("GetBookmarks").Return([]structs.Bookmark{
+					{
+						ID:     1,
+						Title:  "Test Bookmark",
+						URL:    "https://example.com",
+						Text:   "Test content",
+						Time:   1739202544,
+						Folder: "unread",
+					},
+					{
+						ID:     2,
+						Title:  "Test Bookmark 2",
+						URL:    "https://example.com/2",
+						Hash:   "abc456",
+						Text:   "Test content 2",
+						Time:   1739202544,
+						Folder: "unread",
+					},
+				}, nil)
+				mi.On("GetBookmarks", map[string]string{"have": "2:abc456"}).Return([]instapaper.Item{
+					{
+						BookmarkID:        1,
+						Type:              "bookmark",
+						Title:             "Test Bookmark",
+						URL:               "https://example.com",
+						Description:       "Description",
+						Hash:              "abc789",
+						Starred:           "1",
+						Progress:          0.5,
+						ProgressTimestamp: 1739300000,
+						Tags:              []instapaper.Tag{{ID: 7, Name: "go"}},
+						Time:              1739202544,
+					},
+				}, nil)
+				updated := structs.Bookmark{
+					ID:           1,
+					Title:        "Test Bookmark",
+					URL:          "https://example.com",
+					Hash:         "abc789",
+					Text:         "Test content",
+					Time:         1739202544,
+					Description:  "Description",
+					Tags:         []string{"go"},
+					Folder:       "unread",
+					Starred:      true,
+					Progress:     0.5,
+					ProgressTime: 1739300000,
+					SyncedAt:     1740000000,
+				}
+				ms.On("WriteBookmark", &updated).Return(nil)
+				mf.On("Build", []structs.Bookmark{
+					updated,
+					{
+						ID:     2,
+						Title:  "Test Bookmark 2",
+						URL:    "https://example.com/2",
+						Hash:   "abc456",
+						Text:   "Test content 2",
+						Time:   1739202544,
+						Folder: "unread",
 					},
 				}).Return([]byte("feed"), nil)
 			},
 			expectedError: "",
+			expectedRun:   structs.Run{ItemsListed: 1, UpdatedBookmarks: 1, FeedSize: 4, Requests: 2},
 		},
 		{
 			name: "GetBookmarks from Storage error",
@@ -152,6 +291,7 @@ func TestApp_Run(t *testing.T) {
 				ms.On("GetBookmarks").Return([]structs.Bookmark{}, fmt.Errorf("storage error"))
 			},
 			expectedError: "error getting existing bookmarks: storage error",
+			expectedRun:   structs.Run{Failures: 1, Error: "error getting existing bookmarks: storage error", Requests: 2},
 		},
 		{
 			name: "GetBookmarks from Instapaper error",
@@ -160,6 +300,7 @@ func TestApp_Run(t *testing.T) {
 				mi.On("GetBookmarks", map[string]string{}).Return([]instapaper.Item{}, fmt.Errorf("API error"))
 			},
 			expectedError: "error getting bookmarks: API error",
+			expectedRun:   structs.Run{Failures: 1, Error: "error getting bookmarks: API error", Requests: 2},
 		},
 		{
 			name: "GetBookmarkText error",
@@ -178,6 +319,7 @@ func TestApp_Run(t *testing.T) {
 				mi.On("GetBookmarkText", 1).Return("", fmt.Errorf("text fetch error"))
 			},
 			expectedError: "error getting bookmark 1 text: text fetch error",
+			expectedRun:   structs.Run{ItemsListed: 1, Failures: 1, Error: "error getting bookmark 1 text: text fetch error", Requests: 2},
 		},
 		{
 			name: "WriteBookmark error",
@@ -197,6 +339,7 @@ func TestApp_Run(t *testing.T) {
 				ms.On("WriteBookmark", mock.Anything).Return(fmt.Errorf("storage error"))
 			},
 			expectedError: "error writing bookmark 1 text: storage error",
+			expectedRun:   structs.Run{ItemsListed: 1, TextsFetched: 1, Failures: 1, Error: "error writing bookmark 1 text: storage error", Requests: 2},
 		},
 	}
 
@@ -208,7 +351,16 @@ func TestApp_Run(t *testing.T) {
 
 			tt.setupMocks(mockInstapaper, mockStorage, mockFeedBuilder)
 
-			_, err := NewApp(mockInstapaper, mockStorage, mockFeedBuilder).Run("testdata/atom.xml")
+			var run structs.Run
+			mockInstapaper.On("RequestCount").Return(2)
+			mockStorage.On("WriteRun", mock.Anything).Run(func(args mock.Arguments) {
+				run = *args.Get(0).(*structs.Run)
+			}).Return(nil)
+
+			app := NewApp(mockInstapaper, mockStorage, []Output{{Builder: mockFeedBuilder, Path: "testdata/atom.xml"}})
+			app.now = func() time.Time { return time.Unix(1740000000, 0) }
+
+			_, err := app.Run()
 
 			if tt.expectedError != "" {
 				assert.EqualError(t, err, tt.expectedError)
@@ -216,8 +368,497 @@ func TestApp_Run(t *testing.T) {
 				assert.NoError(t, err)
 			}
 
+			tt.expectedRun.Command = "run"
+			tt.expectedRun.Start = time.Unix(1740000000, 0)
+			tt.expectedRun.End = time.Unix(1740000000, 0)
+			assert.Equal(t, tt.expectedRun, run)
+
 			mockInstapaper.AssertExpectations(t)
 			mockStorage.AssertExpectations(t)
 		})
 	}
 }
+
+func TestApp_Run_Folders(t *testing.T) {
+	mockInstapaper := new(MockInstapaper)
+	mockStorage := new(MockStorage)
+	mockAll := new(MockFeedBuilder)
+	mockWork := new(MockFeedBuilder)
+
+	mockStorage.On("GetBookmarks").Return([]structs.Bookmark{}, nil)
+	mockInstapaper.On("GetFolders").Return([]instapaper.Item{
+		{Type: "folder", FolderID: 10, Title: "Personal"},
+		{Type: "folder", FolderID: 11, Title: "Work"},
+	}, nil)
+	mockInstapaper.On("GetBookmarks", map[string]string{}).Return([]instapaper.Item{
+		{Type: "bookmark", BookmarkID: 1, Title: "Unread", Time: 1739202544},
+	}, nil)
+	mockInstapaper.On("GetBookmarks", map[string]string{"folder_id": "11"}).Return([]instapaper.Item{
+		{Type: "bookmark", BookmarkID: 2, Title: "Work", Time: 1739202544},
+	}, nil)
+	mockInstapaper.On("GetBookmarks", map[string]string{"folder_id": "starred"}).Return([]instapaper.Item{
+		{Type: "bookmark", BookmarkID: 1, Title: "Unread", Time: 1739202544, Starred: "1"},
+	}, nil)
+	mockInstapaper.On("GetBookmarkText", 1).Return("Text 1", nil)
+	mockInstapaper.On("GetBookmarkText", 2).Return("Text 2", nil)
+	mockInstapaper.On("RequestCount").Return(6)
+	mockStorage.On("WriteBookmark", mock.Anything).Return(nil)
+	mockStorage.On("WriteRun", mock.Anything).Return(nil)
+
+	unread := structs.Bookmark{ID: 1, Title: "Unread", Time: 1739202544, Text: "Text 1", Folder: "unread", SyncedAt: 1740000000}
+	work := structs.Bookmark{ID: 2, Title: "Work", Time: 1739202544, Text: "Text 2", Folder: "Work", SyncedAt: 1740000000}
+	mockAll.On("Build", []structs.Bookmark{unread, work}).Return([]byte("feed"), nil)
+	mockWork.On("Build", []structs.Bookmark{work}).Return([]byte("feed"), nil)
+
+	app := NewApp(
+		mockInstapaper,
+		mockStorage,
+		[]Output{
+			{Builder: mockAll, Path: "testdata/atom.xml"},
+			{
+				Builder: mockWork,
+				Path:    "testdata/atom.xml",
+				Filter:  func(b structs.Bookmark) bool { return b.Folder == "Work" },
+			},
+		},
+		WithFolders("work", "starred"),
+	)
+	app.now = func() time.Time { return time.Unix(1740000000, 0) }
+
+	run, err := app.Run()
+	assert.NoError(t, err)
+	assert.Equal(t, 2, run.NewBookmarks)
+	assert.Equal(t, 2, run.ItemsListed)
+
+	mockInstapaper.AssertExpectations(t)
+	mockStorage.AssertExpectations(t)
+	mockAll.AssertExpectations(t)
+	mockWork.AssertExpectations(t)
+}
+
+func TestApp_Run_StarredFromFolder(t *testing.T) {
+	mockInstapaper := new(MockInstapaper)
+	mockStorage := new(MockStorage)
+	mockStarred := new(MockFeedBuilder)
+	mockWork := new(MockFeedBuilder)
+
+	mockStorage.On("GetBookmarks").Return([]structs.Bookmark{}, nil)
+	mockInstapaper.On("GetFolders").Return([]instapaper.Item{
+		{Type: "folder", FolderID: 11, Title: "Work"},
+	}, nil)
+	mockInstapaper.On("GetBookmarks", map[string]string{}).Return([]instapaper.Item{
+		{Type: "bookmark", BookmarkID: 1, Title: "Unread", Time: 1739202544, Starred: "1"},
+	}, nil)
+	mockInstapaper.On("GetBookmarks", map[string]string{"folder_id": "11"}).Return([]instapaper.Item{
+		{Type: "bookmark", BookmarkID: 2, Title: "Work", Time: 1739202544, Starred: "1"},
+	}, nil)
+	// the third bookmark is archived, which isn't synced
+	mockInstapaper.On("GetBookmarks", map[string]string{"folder_id": "starred"}).Return([]instapaper.Item{
+		{Type: "bookmark", BookmarkID: 1, Title: "Unread", Time: 1739202544, Starred: "1"},
+		{Type: "bookmark", BookmarkID: 2, Title: "Work", Time: 1739202544, Starred: "1"},
+		{Type: "bookmark", BookmarkID: 3, Title: "Archived", Time: 1739202544, Starred: "1"},
+	}, nil)
+	for id := 1; id <= 3; id++ {
+		mockInstapaper.On("GetBookmarkText", id).Return("Text", nil)
+	}
+	mockInstapaper.On("RequestCount").Return(7)
+	mockStorage.On("WriteBookmark", mock.Anything).Return(nil)
+	mockStorage.On("WriteRun", mock.Anything).Return(nil)
+
+	unread := structs.Bookmark{ID: 1, Title: "Unread", Time: 1739202544, Text: "Text", Folder: "unread", Starred: true, SyncedAt: 1740000000}
+	work := structs.Bookmark{ID: 2, Title: "Work", Time: 1739202544, Text: "Text", Folder: "Work", Starred: true, SyncedAt: 1740000000}
+	archived := structs.Bookmark{ID: 3, Title: "Archived", Time: 1739202544, Text: "Text", Starred: true, SyncedAt: 1740000000}
+	mockStarred.On("Build", []structs.Bookmark{unread, work, archived}).Return([]byte("feed"), nil)
+	mockWork.On("Build", []structs.Bookmark{work}).Return([]byte("feed"), nil)
+
+	feeds, err := parseFeeds("folder:starred=starred.xml\nfolder:Work=work.xml")
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	app := NewApp(
+		mockInstapaper,
+		mockStorage,
+		[]Output{
+			{Builder: mockStarred, Path: filepath.Join(dir, "starred.xml"), Filter: feeds[0].filter()},
+			{Builder: mockWork, Path: filepath.Join(dir, "work.xml"), Filter: feeds[1].filter()},
+		},
+		// starred is listed last, whatever the order of feeds
+		WithFolders(feeds.folders()...),
+	)
+	app.now = func() time.Time { return time.Unix(1740000000, 0) }
+
+	run, err := app.Run()
+	require.NoError(t, err)
+	assert.Equal(t, 3, run.NewBookmarks)
+	assert.Equal(t, 3, run.ItemsListed)
+
+	mockInstapaper.AssertExpectations(t)
+	mockStarred.AssertExpectations(t)
+	mockWork.AssertExpectations(t)
+}
+
+// filesProcessor reports files without changing bookmarks.
+type filesProcessor struct {
+	files []string
+}
+
+func (p filesProcessor) Process(*structs.Bookmark) error { return nil }
+
+func (p filesProcessor) Files() []string { return p.files }
+
+func TestApp_Run_MovedToFolder(t *testing.T) {
+	mockInstapaper := new(MockInstapaper)
+	mockStorage := new(MockStorage)
+	mockWork := new(MockFeedBuilder)
+
+	moved := structs.Bookmark{ID: 1, Title: "Moved", Hash: "abc", Time: 1739202544, Text: "Text", Folder: "unread"}
+	unread := structs.Bookmark{ID: 2, Title: "Unread", Hash: "def", Time: 1739202544, Text: "Text", Folder: "unread"}
+
+	mockStorage.On("GetBookmarks").Return([]structs.Bookmark{moved, unread}, nil)
+	mockInstapaper.On("GetFolders").Return([]instapaper.Item{
+		{Type: "folder", FolderID: 11, Title: "Work"},
+	}, nil)
+	mockInstapaper.On("GetBookmarks", map[string]string{"have": "1:abc,2:def"}).Return([]instapaper.Item{}, nil)
+	// the moved bookmark keeps its hash, it is listed as it isn't known in Work
+	mockInstapaper.On("GetBookmarks", map[string]string{"folder_id": "11"}).Return([]instapaper.Item{
+		{Type: "bookmark", BookmarkID: 1, Title: "Moved", Hash: "abc", Time: 1739202544},
+	}, nil)
+	mockInstapaper.On("RequestCount").Return(3)
+	mockStorage.On("WriteBookmark", mock.MatchedBy(func(b *structs.Bookmark) bool {
+		return b.ID == 1 && b.Folder == "Work"
+	})).Return(nil)
+	mockStorage.On("WriteRun", mock.Anything).Return(nil)
+
+	moved.Folder = "Work"
+	moved.SyncedAt = 1740000000
+	mockWork.On("Build", []structs.Bookmark{moved}).Return([]byte("feed"), nil)
+
+	app := NewApp(
+		mockInstapaper,
+		mockStorage,
+		[]Output{{
+			Builder: mockWork,
+			Path:    filepath.Join(t.TempDir(), "work.xml"),
+			Filter:  func(b structs.Bookmark) bool { return b.Folder == "Work" },
+		}},
+		WithFolders("Work"),
+	)
+	app.now = func() time.Time { return time.Unix(1740000000, 0) }
+
+	run, err := app.Run()
+	require.NoError(t, err)
+	assert.Equal(t, 1, run.UpdatedBookmarks)
+
+	mockInstapaper.AssertExpectations(t)
+	mockStorage.AssertExpectations(t)
+	mockWork.AssertExpectations(t)
+}
+
+func TestApp_Run_Processors(t *testing.T) {
+	mockInstapaper := new(MockInstapaper)
+	mockStorage := new(MockStorage)
+	mockFeedBuilder := new(MockFeedBuilder)
+
+	mockStorage.On("GetBookmarks").Return([]structs.Bookmark{}, nil)
+	mockInstapaper.On("GetBookmarks", map[string]string{}).Return([]instapaper.Item{
+		{Type: "bookmark", BookmarkID: 1, Title: "Title", Time: 1739202544},
+	}, nil)
+	mockInstapaper.On("GetBookmarkText", 1).Return(`<p onclick="x()">Text</p><script>track()</script>`, nil)
+	mockInstapaper.On("RequestCount").Return(2)
+	mockStorage.On("WriteRun", mock.Anything).Return(nil)
+
+	expected := structs.Bookmark{
+		ID:       1,
+		Title:    "Title",
+		Time:     1739202544,
+		Text:     "<p>Text</p>",
+		Folder:   "unread",
+		SyncedAt: 1740000000,
+	}
+	mockStorage.On("WriteBookmark", &expected).Return(nil)
+	mockFeedBuilder.On("Build", []structs.Bookmark{expected}).Return([]byte("feed"), nil)
+
+	app := NewApp(
+		mockInstapaper,
+		mockStorage,
+		[]Output{{Builder: mockFeedBuilder, Path: "testdata/atom.xml"}},
+		WithProcessors(
+			sanitizer{policy: sanitize.Strict()},
+			filesProcessor{files: []string{"images/photo.png"}},
+		),
+	)
+	app.now = func() time.Time { return time.Unix(1740000000, 0) }
+
+	run, err := app.Run()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"images/photo.png"}, run.Files)
+
+	mockInstapaper.AssertExpectations(t)
+	mockStorage.AssertExpectations(t)
+	mockFeedBuilder.AssertExpectations(t)
+}
+
+func TestApp_Run_UnknownFolder(t *testing.T) {
+	mockInstapaper := new(MockInstapaper)
+	mockStorage := new(MockStorage)
+
+	mockStorage.On("GetBookmarks").Return([]structs.Bookmark{}, nil)
+	mockInstapaper.On("GetFolders").Return([]instapaper.Item{}, nil)
+	mockInstapaper.On("RequestCount").Return(1)
+	mockStorage.On("WriteRun", mock.Anything).Return(nil)
+
+	_, err := NewApp(mockInstapaper, mockStorage, nil, WithFolders("Work")).Run()
+	assert.EqualError(t, err, `error getting folders: folder "Work" not found`)
+}
+
+func TestApp_Run_InvalidFeed(t *testing.T) {
+	mockInstapaper := new(MockInstapaper)
+	mockStorage := new(MockStorage)
+	mockFeedBuilder := new(MockFeedBuilder)
+
+	mockStorage.On("GetBookmarks").Return([]structs.Bookmark{}, nil)
+	mockInstapaper.On("GetBookmarks", map[string]string{}).Return([]instapaper.Item{
+		{Type: "bookmark", BookmarkID: 1, Title: "Test Bookmark", Time: 1739202544},
+	}, nil)
+	mockInstapaper.On("GetBookmarkText", 1).Return("Test content", nil)
+	mockInstapaper.On("RequestCount").Return(2)
+	mockStorage.On("WriteBookmark", mock.Anything).Return(nil)
+	mockStorage.On("WriteRun", mock.Anything).Return(nil)
+	mockFeedBuilder.On("Build", mock.Anything).Return([]byte("feed"), nil)
+
+	dir := t.TempDir()
+	valid := filepath.Join(dir, "valid.xml")
+	invalid := filepath.Join(dir, "invalid.xml")
+
+	_, err := NewApp(mockInstapaper, mockStorage, []Output{
+		{Builder: mockFeedBuilder, Path: valid},
+		{
+			Builder:  mockFeedBuilder,
+			Path:     invalid,
+			Validate: func(io.Reader) error { return fmt.Errorf("missing id") },
+		},
+	}).Run()
+	assert.EqualError(t, err, "invalid feed "+invalid+": missing id")
+
+	// temporary files are removed too
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+// metaStorage is a MockStorage that keeps metadata in memory.
+type metaStorage struct {
+	*MockStorage
+	meta map[string]string
+}
+
+func (m metaStorage) GetMeta(key string) (string, error) {
+	return m.meta[key], nil
+}
+
+func (m metaStorage) SetMeta(key, value string) error {
+	m.meta[key] = value
+	return nil
+}
+
+func TestApp_Run_FeedChanged(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "atom.xml")
+
+	mockInstapaper := new(MockInstapaper)
+	mockStorage := new(MockStorage)
+	mockFeedBuilder := new(MockFeedBuilder)
+
+	mockStorage.On("GetBookmarks").Return([]structs.Bookmark{}, nil)
+	mockInstapaper.On("GetBookmarks", map[string]string{}).Return([]instapaper.Item{
+		{Type: "bookmark", BookmarkID: 1, Title: "Title", Time: 1739202544},
+	}, nil)
+	mockInstapaper.On("GetBookmarkText", 1).Return("Text", nil)
+	mockInstapaper.On("RequestCount").Return(2)
+	mockStorage.On("WriteBookmark", mock.Anything).Return(nil)
+	mockStorage.On("WriteRun", mock.Anything).Return(nil)
+	mockFeedBuilder.On("Build", mock.Anything).Return([]byte("feed"), nil)
+
+	storage := metaStorage{MockStorage: mockStorage, meta: map[string]string{}}
+	app := NewApp(mockInstapaper, storage, []Output{{Builder: mockFeedBuilder, Path: path}})
+
+	run, err := app.Run()
+	require.NoError(t, err)
+	assert.True(t, run.FeedChanged)
+	assert.Equal(t, []string{path}, run.Files)
+	// SHA-256 of "feed"
+	assert.Equal(t, "c8bc2586cdd87cd6f970fc4262c4bbc49165811788825fbc1ba3366bfde45413", storage.meta["file_hash:"+path])
+
+	// the feed is missing locally, as on a fresh checkout:
+	// it is written again, but has not changed since the previous run
+	require.NoError(t, os.Remove(path))
+
+	run, err = app.Run()
+	require.NoError(t, err)
+	assert.False(t, run.FeedChanged)
+	assert.Empty(t, run.Files)
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "feed", string(data))
+}
+
+func TestApp_Sync(t *testing.T) {
+	mockInstapaper := new(MockInstapaper)
+	mockStorage := new(MockStorage)
+	mockFeedBuilder := new(MockFeedBuilder)
+
+	mockStorage.On("GetBookmarks").Return([]structs.Bookmark{}, nil)
+	mockInstapaper.On("GetBookmarks", map[string]string{}).Return([]instapaper.Item{
+		{Type: "bookmark", BookmarkID: 1, Title: "Title", Time: 1739202544},
+	}, nil)
+	mockInstapaper.On("GetBookmarkText", 1).Return("Text", nil)
+	mockInstapaper.On("RequestCount").Return(2)
+	mockStorage.On("WriteBookmark", mock.Anything).Return(nil)
+	mockStorage.On("WriteRun", mock.Anything).Return(nil)
+
+	app := NewApp(mockInstapaper, mockStorage, []Output{{Builder: mockFeedBuilder, Path: "testdata/atom.xml"}})
+
+	run, err := app.Sync()
+	require.NoError(t, err)
+	assert.Equal(t, "sync", run.Command)
+	assert.Equal(t, 1, run.NewBookmarks)
+	assert.Zero(t, run.FeedSize)
+	assert.Empty(t, run.Files)
+
+	mockFeedBuilder.AssertNotCalled(t, "Build", mock.Anything)
+}
+
+func TestApp_Build(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "atom.xml")
+	bookmarks := []structs.Bookmark{
+		{ID: 1, Title: "Title", Text: "Text", Time: 1739202544},
+	}
+
+	mockStorage := new(MockStorage)
+	mockFeedBuilder := new(MockFeedBuilder)
+
+	mockStorage.On("GetBookmarks").Return(bookmarks, nil)
+	mockStorage.On("WriteRun", mock.Anything).Return(nil)
+	mockFeedBuilder.On("Build", bookmarks).Return([]byte("feed"), nil)
+
+	// builds don't need Instapaper
+	app := NewApp(nil, mockStorage, []Output{{Builder: mockFeedBuilder, Path: path}})
+
+	run, err := app.Build()
+	require.NoError(t, err)
+	assert.Equal(t, "build", run.Command)
+	assert.Equal(t, 4, run.FeedSize)
+	assert.Zero(t, run.Requests)
+	assert.Equal(t, []string{path}, run.Files)
+
+	mockStorage.AssertExpectations(t)
+	mockFeedBuilder.AssertExpectations(t)
+}
+
+// writerBuilder streams the feed, Build must not be called.
+type writerBuilder struct {
+	feed string
+}
+
+func (wb writerBuilder) Build([]structs.Bookmark) ([]byte, error) {
+	return nil, fmt.Errorf("feed not streamed")
+}
+
+func (wb writerBuilder) BuildTo(w io.Writer, _ []structs.Bookmark) error {
+	_, err := io.WriteString(w, wb.feed)
+	return err
+}
+
+func TestApp_Build_Precompress(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "feed.xml")
+	feed := strings.Repeat("<entry>feed</entry>\n", 100)
+
+	mockStorage := new(MockStorage)
+	mockStorage.On("GetBookmarks").Return([]structs.Bookmark{}, nil)
+	mockStorage.On("WriteRun", mock.Anything).Return(nil)
+
+	app := NewApp(nil, mockStorage, []Output{{
+		Builder:   writerBuilder{feed: feed},
+		Path:      path,
+		Encodings: []precompress.Encoding{precompress.Gzip, precompress.Brotli},
+	}})
+
+	run, err := app.Build()
+	require.NoError(t, err)
+	assert.Equal(t, []string{path, path + ".gz", path + ".br"}, run.Files)
+	assert.Equal(t, len(feed), run.FeedSize)
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, feed, string(data))
+
+	gz, err := os.Open(path + ".gz")
+	require.NoError(t, err)
+	defer gz.Close()
+	zr, err := gzip.NewReader(gz)
+	require.NoError(t, err)
+	data, err = io.ReadAll(zr)
+	require.NoError(t, err)
+	assert.Equal(t, feed, string(data))
+
+	br, err := os.Open(path + ".br")
+	require.NoError(t, err)
+	defer br.Close()
+	data, err = io.ReadAll(brotli.NewReader(br))
+	require.NoError(t, err)
+	assert.Equal(t, feed, string(data))
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 3, "no temporary files are left")
+
+	// compressed files are the same for the same feed
+	run, err = app.Build()
+	require.NoError(t, err)
+	assert.Empty(t, run.Files)
+}
+
+func TestApp_Build_Prune(t *testing.T) {
+	dir := t.TempDir()
+	feed := filepath.Join(dir, "atom.xml")
+	require.NoError(t, os.WriteFile(feed, []byte("feed"), 0o644))
+
+	bookmarks := []structs.Bookmark{
+		{ID: 1, Title: "First", Time: 1739202544, Tags: []string{"go"}},
+		{ID: 2, Title: "Second", Time: 1739300000, Tags: []string{"Long reads"}},
+	}
+
+	mockStorage := new(MockStorage)
+	mockStorage.On("GetBookmarks").Return(bookmarks, nil).Once()
+	mockStorage.On("GetBookmarks").Return(bookmarks[:1], nil).Once()
+	mockStorage.On("WriteRun", mock.Anything).Return(nil)
+
+	storage := metaStorage{MockStorage: mockStorage, meta: map[string]string{}}
+	app := NewApp(nil, storage, []Output{{
+		Builder:   site.Builder{},
+		Path:      dir,
+		Encodings: []precompress.Encoding{precompress.Gzip},
+	}})
+
+	_, err := app.Build()
+	require.NoError(t, err)
+	article := filepath.Join(dir, "articles", "2.html")
+	tag := filepath.Join(dir, "tags", "long-reads-c3b873ea.html")
+	for _, path := range []string{article, article + ".gz", tag} {
+		assert.FileExists(t, path)
+	}
+
+	// the second bookmark was deleted
+	run, err := app.Build()
+	require.NoError(t, err)
+	assert.True(t, run.FeedChanged)
+	for _, path := range []string{article, article + ".gz", tag, tag + ".gz"} {
+		assert.NoFileExists(t, path)
+		assert.Empty(t, storage.meta["file_hash:"+path])
+	}
+	assert.FileExists(t, filepath.Join(dir, "articles", "1.html"))
+	assert.FileExists(t, filepath.Join(dir, "tags", "go.html"))
+	assert.FileExists(t, feed, "files not written by the site are kept")
+}
@@ -0,0 +1,507 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/chuhlomin/instapaper2rss/pkg/atomicfile"
+	"github.com/chuhlomin/instapaper2rss/pkg/bolt"
+	"github.com/chuhlomin/instapaper2rss/pkg/jsonl"
+	"github.com/chuhlomin/instapaper2rss/pkg/markdown"
+	"github.com/chuhlomin/instapaper2rss/pkg/slug"
+	"github.com/chuhlomin/instapaper2rss/pkg/structs"
+	"github.com/chuhlomin/instapaper2rss/pkg/validate"
+)
+
+func runExport(args []string) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	output := fs.String("output", "", "output file (default stdout)")
+	filter := filterFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	f, err := filter()
+	if err != nil {
+		return err
+	}
+
+	storage, err := openStorage()
+	if err != nil {
+		return err
+	}
+	defer storage.Close()
+
+	bookmarks, err := storage.GetBookmarks()
+	if err != nil {
+		return fmt.Errorf("failed to get bookmarks: %w", err)
+	}
+
+	var w io.Writer = os.Stdout
+	var file *atomicfile.File
+	if *output != "" {
+		// a failed export leaves the previous backup in place
+		if file, err = atomicfile.Create(*output); err != nil {
+			return fmt.Errorf("failed to create output file: %w", err)
+		}
+		defer file.Abort()
+		w = file
+	}
+
+	count, err := jsonl.Export(w, bookmarks, f)
+	if err != nil {
+		return fmt.Errorf("failed to export bookmarks: %w", err)
+	}
+
+	if file != nil {
+		if err := file.Commit(); err != nil {
+			return fmt.Errorf("failed to write output file: %w", err)
+		}
+	}
+
+	log.Printf("Exported %d bookmarks", count)
+	return nil
+}
+
+func runImport(args []string) error {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	filter := filterFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	f, err := filter()
+	if err != nil {
+		return err
+	}
+
+	var r io.Reader = os.Stdin
+	if path := fs.Arg(0); path != "" && path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			return fmt.Errorf("failed to open input file: %w", err)
+		}
+		defer file.Close()
+		r = file
+	}
+
+	storage, err := openStorage()
+	if err != nil {
+		return err
+	}
+	defer storage.Close()
+
+	count, err := jsonl.Import(r, storage, f)
+	if err != nil {
+		return fmt.Errorf("failed to import bookmarks (%d imported): %w", count, err)
+	}
+
+	log.Printf("Imported %d bookmarks", count)
+	return nil
+}
+
+// epubLastExportKey stores the time of the newest bookmark
+// in the last EPUB export, for -since-last-export.
+const epubLastExportKey = "epub_last_export"
+
+func runEPUB(args []string) error {
+	fs := flag.NewFlagSet("epub", flag.ContinueOnError)
+	output := fs.String("output", "instapaper.epub", "output file")
+	folder := fs.String("folder", "", "only bookmarks from this folder")
+	sinceLast := fs.Bool("since-last-export", false, "only bookmarks saved after the newest one in the last export")
+	filter := filterFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	f, err := filter()
+	if err != nil {
+		return err
+	}
+
+	storage, err := openStorage()
+	if err != nil {
+		return err
+	}
+	defer storage.Close()
+
+	bookmarks, err := storage.GetBookmarks()
+	if err != nil {
+		return fmt.Errorf("failed to get bookmarks: %w", err)
+	}
+
+	var lastExport int64
+	value, err := storage.GetMeta(epubLastExportKey)
+	if err != nil {
+		return fmt.Errorf("failed to get last export: %w", err)
+	}
+	if value != "" {
+		if lastExport, err = strconv.ParseInt(value, 10, 64); err != nil {
+			return fmt.Errorf("invalid last export %q: %w", value, err)
+		}
+	}
+
+	var selected []structs.Bookmark
+	newest := lastExport
+	for _, b := range bookmarks {
+		if !f.Match(b) || *sinceLast && b.Time <= lastExport {
+			continue
+		}
+		if *folder != "" && !inFolder(b, *folder) {
+			continue
+		}
+
+		selected = append(selected, b)
+		newest = max(newest, b.Time)
+	}
+
+	if len(selected) == 0 {
+		log.Printf("No bookmarks to export")
+		return nil
+	}
+
+	data, err := createEPUBBuilder(getEnvVar("FEED_TITLE", "Instapaper")).Build(selected)
+	if err != nil {
+		return fmt.Errorf("failed to build EPUB: %w", err)
+	}
+
+	if err := os.WriteFile(*output, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write EPUB: %w", err)
+	}
+
+	if err := storage.SetMeta(epubLastExportKey, strconv.FormatInt(newest, 10)); err != nil {
+		return fmt.Errorf("failed to save last export: %w", err)
+	}
+
+	log.Printf("Exported %d bookmarks to %s", len(selected), *output)
+	return nil
+}
+
+func runExportMarkdown(args []string) error {
+	fs := flag.NewFlagSet("export-markdown", flag.ContinueOnError)
+	dir := fs.String("dir", "notes", "directory to write notes into")
+	folder := fs.String("folder", "", "only bookmarks from this folder")
+	filter := filterFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	f, err := filter()
+	if err != nil {
+		return err
+	}
+
+	storage, err := openStorage()
+	if err != nil {
+		return err
+	}
+	defer storage.Close()
+
+	bookmarks, err := storage.GetBookmarks()
+	if err != nil {
+		return fmt.Errorf("failed to get bookmarks: %w", err)
+	}
+
+	var selected []structs.Bookmark
+	for _, b := range bookmarks {
+		if !f.Match(b) {
+			continue
+		}
+		if *folder != "" && !inFolder(b, *folder) {
+			continue
+		}
+		selected = append(selected, b)
+	}
+
+	written, err := exportNotes(*dir, selected)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Exported %d bookmarks to %s, %d notes written", len(selected), *dir, written)
+	return nil
+}
+
+// exportNotes writes a Markdown note per bookmark into dir, keeping
+// the user's notes below the marker in existing files, and returns
+// the number of files written. Notes with unchanged content,
+// or edited without the marker, are left as they are.
+func exportNotes(dir string, bookmarks []structs.Bookmark) (int, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return 0, fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	sorted := slices.Clone(bookmarks)
+	slices.SortFunc(sorted, func(a, b structs.Bookmark) int {
+		return a.ID - b.ID
+	})
+
+	notes, err := findNotes(dir)
+	if err != nil {
+		return 0, err
+	}
+
+	written := 0
+	for _, b := range sorted {
+		note, err := markdown.Note(b)
+		if err != nil {
+			return written, fmt.Errorf("failed to convert bookmark %d: %w", b.ID, err)
+		}
+
+		path, err := notePath(dir, b, notes)
+		if err != nil {
+			return written, err
+		}
+
+		existing, err := os.ReadFile(path)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return written, fmt.Errorf("failed to read note: %w", err)
+		}
+
+		merged, ok := markdown.Merge(note, string(existing))
+		if !ok {
+			log.Printf("Skipping %s: notes marker not found", path)
+			continue
+		}
+
+		if merged == string(existing) {
+			continue
+		}
+		if err := atomicfile.WriteFile(path, []byte(merged)); err != nil {
+			return written, fmt.Errorf("failed to write note %s: %w", path, err)
+		}
+		written++
+	}
+
+	return written, nil
+}
+
+// notePath returns the path of the bookmark's note, a slug of the title
+// with the ID appended, so names don't depend on which bookmarks are exported.
+// A note found by its ID under another name, such as one named after
+// the previous title or without the ID by earlier exports, is renamed,
+// so the user's notes in it are kept.
+func notePath(dir string, b structs.Bookmark, notes map[int]string) (string, error) {
+	name := slug.Make(b.Title)
+	if name == "" {
+		name = "bookmark"
+	}
+
+	path := filepath.Join(dir, name+"-"+strconv.Itoa(b.ID)+".md")
+	old, ok := notes[b.ID]
+	if !ok || old == path {
+		return path, nil
+	}
+
+	if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+		return path, nil
+	}
+
+	if err := os.Rename(old, path); err != nil {
+		return "", fmt.Errorf("failed to rename note %s: %w", old, err)
+	}
+	notes[b.ID] = path
+	return path, nil
+}
+
+// findNotes returns the paths of notes in dir by the bookmark IDs
+// in their front matter.
+func findNotes(dir string) (map[int]string, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.md"))
+	if err != nil {
+		return nil, err
+	}
+
+	notes := make(map[int]string, len(paths))
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read note: %w", err)
+		}
+
+		id, ok := markdown.ID(string(data))
+		if !ok {
+			continue
+		}
+		// prefer the note with the ID in its name, if there are several
+		if _, dup := notes[id]; !dup || strings.HasSuffix(path, "-"+strconv.Itoa(id)+".md") {
+			notes[id] = path
+		}
+	}
+
+	return notes, nil
+}
+
+func runValidate(args []string) error {
+	fs := flag.NewFlagSet("validate", flag.ContinueOnError)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() == 0 {
+		return fmt.Errorf("usage: validate <file>...")
+	}
+
+	failed := 0
+	for _, path := range fs.Args() {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read feed: %w", err)
+		}
+
+		if err := validate.Feed(data); err != nil {
+			failed++
+			fmt.Printf("%s: invalid\n", path)
+			for _, line := range strings.Split(err.Error(), "\n") {
+				fmt.Printf("  %s\n", line)
+			}
+			continue
+		}
+
+		fmt.Printf("%s: valid\n", path)
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d feeds are invalid", failed, fs.NArg())
+	}
+	return nil
+}
+
+type status struct {
+	Stats       bolt.Stats
+	LastSuccess *structs.Run
+	Runs        []structs.Run
+}
+
+func runStatus(args []string) error {
+	fs := flag.NewFlagSet("status", flag.ContinueOnError)
+	limit := fs.Int("n", 10, "number of recent runs to show")
+	format := fs.String("format", "table", "output format: table or json")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *format != "table" && *format != "json" {
+		return fmt.Errorf("unknown format %q", *format)
+	}
+
+	storage, err := openStorage()
+	if err != nil {
+		return err
+	}
+	defer storage.Close()
+
+	var st status
+
+	if st.Stats, err = storage.Stats(); err != nil {
+		return fmt.Errorf("failed to get storage stats: %w", err)
+	}
+
+	if st.LastSuccess, err = storage.LastSuccessfulRun(); err != nil {
+		return fmt.Errorf("failed to get last successful run: %w", err)
+	}
+
+	if st.Runs, err = storage.GetRuns(*limit); err != nil {
+		return fmt.Errorf("failed to get runs: %w", err)
+	}
+
+	if *format == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(st)
+	}
+
+	return printStatus(os.Stdout, st)
+}
+
+func printStatus(out io.Writer, st status) error {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintf(w, "Bookmarks:\t%d\n", st.Stats.Bookmarks)
+	fmt.Fprintf(w, "Runs:\t%d\n", st.Stats.Runs)
+	fmt.Fprintf(w, "Database size:\t%d bytes\n", st.Stats.Size)
+	if st.LastSuccess != nil {
+		fmt.Fprintf(w, "Last successful sync:\t%s\n", st.LastSuccess.End.Format(time.RFC3339))
+	} else {
+		fmt.Fprintf(w, "Last successful sync:\tnever\n")
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if len(st.Runs) == 0 {
+		return nil
+	}
+
+	fmt.Fprintln(out)
+	fmt.Fprintln(w, "ID\tCOMMAND\tSTARTED\tDURATION\tLISTED\tFETCHED\tNEW\tFAILURES\tFEED SIZE\tREQUESTS\tERROR")
+	for _, r := range st.Runs {
+		command := r.Command
+		if command == "" {
+			command = "run"
+		}
+
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%d\t%d\t%d\t%d\t%d\t%d\t%s\n",
+			r.ID,
+			command,
+			r.Start.Format(time.RFC3339),
+			r.End.Sub(r.Start).Round(time.Millisecond),
+			r.ItemsListed,
+			r.TextsFetched,
+			r.NewBookmarks,
+			r.Failures,
+			r.FeedSize,
+			r.Requests,
+			r.Error,
+		)
+	}
+
+	return w.Flush()
+}
+
+// filterFlags registers -since and -until on fs and returns
+// a function that builds the filter once fs is parsed.
+func filterFlags(fs *flag.FlagSet) func() (jsonl.Filter, error) {
+	since := fs.String("since", "", "only bookmarks saved at or after this date (YYYY-MM-DD or RFC 3339)")
+	until := fs.String("until", "", "only bookmarks saved before this date (YYYY-MM-DD or RFC 3339)")
+
+	return func() (jsonl.Filter, error) {
+		var (
+			f   jsonl.Filter
+			err error
+		)
+
+		if f.Since, err = parseDate(*since); err != nil {
+			return f, fmt.Errorf("invalid -since: %w", err)
+		}
+
+		if f.Until, err = parseDate(*until); err != nil {
+			return f, fmt.Errorf("invalid -until: %w", err)
+		}
+
+		return f, nil
+	}
+}
+
+func parseDate(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+
+	if t, err := time.Parse(time.DateOnly, s); err == nil {
+		return t, nil
+	}
+
+	return time.Parse(time.RFC3339, s)
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/chuhlomin/instapaper2rss/pkg/bolt"
+	"github.com/chuhlomin/instapaper2rss/pkg/markdown"
+	"github.com/chuhlomin/instapaper2rss/pkg/structs"
+)
+
+func TestExportNotes(t *testing.T) {
+	dir := t.TempDir()
+	bookmarks := []structs.Bookmark{
+		{ID: 2, Title: "Same title", URL: "https://example.com/2", Text: "<p>Second</p>"},
+		{ID: 1, Title: "Same title", URL: "https://example.com/1", Text: "<p>First</p>"},
+		{ID: 3, URL: "https://example.com/3", Text: "<p>Untitled</p>"},
+	}
+
+	written, err := exportNotes(dir, bookmarks)
+	require.NoError(t, err)
+	assert.Equal(t, 3, written)
+
+	first, err := os.ReadFile(filepath.Join(dir, "same-title-1.md"))
+	require.NoError(t, err)
+	assert.Contains(t, string(first), "First")
+
+	second, err := os.ReadFile(filepath.Join(dir, "same-title-2.md"))
+	require.NoError(t, err)
+	assert.Contains(t, string(second), "Second")
+
+	_, err = os.Stat(filepath.Join(dir, "bookmark-3.md"))
+	require.NoError(t, err)
+
+	// notes below the marker are kept, and unchanged notes are not rewritten
+	path := filepath.Join(dir, "same-title-1.md")
+	require.NoError(t, os.WriteFile(path, append(first, "\nMy notes\n"...), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "bookmark-3.md"), []byte("edited"), 0o644))
+
+	bookmarks[1].Highlights = []structs.Highlight{{ID: 1, Text: "A passage"}}
+	written, err = exportNotes(dir, bookmarks)
+	require.NoError(t, err)
+	assert.Equal(t, 1, written)
+
+	updated, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.True(t, strings.HasSuffix(string(updated), "> A passage\n\n"+markdown.Marker+"\n\nMy notes\n"))
+
+	edited, err := os.ReadFile(filepath.Join(dir, "bookmark-3.md"))
+	require.NoError(t, err)
+	assert.Equal(t, "edited", string(edited))
+}
+
+func TestExportNotes_Filtered(t *testing.T) {
+	dir := t.TempDir()
+	first := structs.Bookmark{ID: 1, Title: "Same title", Text: "<p>First</p>"}
+	second := structs.Bookmark{ID: 2, Title: "Same title", Text: "<p>Second</p>"}
+
+	// a note named without the ID by an earlier export
+	note, err := markdown.Note(first)
+	require.NoError(t, err)
+	legacy := filepath.Join(dir, "same-title.md")
+	require.NoError(t, os.WriteFile(legacy, []byte(note+"\nMy notes\n"), 0o644))
+
+	// exporting only the second bookmark, e.g. with -since,
+	// doesn't take the first one's note
+	_, err = exportNotes(dir, []structs.Bookmark{second})
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(filepath.Join(dir, "same-title-2.md"))
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "My notes")
+	assert.FileExists(t, legacy)
+
+	_, err = exportNotes(dir, []structs.Bookmark{first, second})
+	require.NoError(t, err)
+
+	assert.NoFileExists(t, legacy)
+	data, err = os.ReadFile(filepath.Join(dir, "same-title-1.md"))
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "First")
+	assert.Contains(t, string(data), "My notes")
+}
+
+func TestExportNotes_Renamed(t *testing.T) {
+	dir := t.TempDir()
+	b := structs.Bookmark{ID: 1, Title: "Old title", Text: "<p>Text</p>"}
+
+	_, err := exportNotes(dir, []structs.Bookmark{b})
+	require.NoError(t, err)
+	old := filepath.Join(dir, "old-title-1.md")
+	data, err := os.ReadFile(old)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(old, append(data, "\nMy notes\n"...), 0o644))
+
+	// the title changed in Instapaper: the note follows it
+	b.Title = "New title"
+	written, err := exportNotes(dir, []structs.Bookmark{b})
+	require.NoError(t, err)
+	assert.Equal(t, 1, written)
+
+	assert.NoFileExists(t, old)
+	data, err = os.ReadFile(filepath.Join(dir, "new-title-1.md"))
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "New title")
+	assert.Contains(t, string(data), "My notes")
+}
+
+func TestRunExport(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("STORAGE_PATH", filepath.Join(dir, "instapaper.db"))
+
+	storage, err := bolt.NewStorage(filepath.Join(dir, "instapaper.db"))
+	require.NoError(t, err)
+	require.NoError(t, storage.WriteBookmark(&structs.Bookmark{ID: 1, Title: "Title", Time: 1739202544}))
+	require.NoError(t, storage.Close())
+
+	output := filepath.Join(dir, "backup", "bookmarks.jsonl")
+	require.NoError(t, runExport([]string{"-output", output}))
+
+	data, err := os.ReadFile(output)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"Title":"Title"`)
+
+	entries, err := os.ReadDir(filepath.Dir(output))
+	require.NoError(t, err)
+	assert.Len(t, entries, 1, "no temporary files are left")
+}
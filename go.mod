@@ -3,8 +3,10 @@ module github.com/chuhlomin/instapaper2rss
 go 1.22.10
 
 require (
+	github.com/andybalholm/brotli v1.1.1
 	github.com/boltdb/bolt v1.3.1
 	github.com/stretchr/testify v1.10.0
+	golang.org/x/net v0.35.0
 )
 
 require (
@@ -7,11 +7,12 @@ import (
 	"log"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
-	"github.com/chuhlomin/instapaper2rss/pkg/atom"
 	"github.com/chuhlomin/instapaper2rss/pkg/bolt"
 	"github.com/chuhlomin/instapaper2rss/pkg/instapaper"
+	"github.com/chuhlomin/instapaper2rss/pkg/structs"
 )
 
 func main() {
@@ -24,27 +25,126 @@ func main() {
 	log.Printf("Done.")
 }
 
+var (
+	username = flag.String("username", "", "Instapaper username")
+	password = flag.String("password", "", "Instapaper password")
+)
+
 func run() error {
+	flag.Usage = usage
+	flag.Parse()
+
+	switch cmd := flag.Arg(0); cmd {
+	case "", "run", "sync", "build":
+		return runApp(cmd)
+	case "export":
+		return runExport(flag.Args()[1:])
+	case "import":
+		return runImport(flag.Args()[1:])
+	case "epub":
+		return runEPUB(flag.Args()[1:])
+	case "export-markdown":
+		return runExportMarkdown(flag.Args()[1:])
+	case "status":
+		return runStatus(flag.Args()[1:])
+	case "validate":
+		return runValidate(flag.Args()[1:])
+	default:
+		flag.Usage()
+		return fmt.Errorf("unknown command %q", cmd)
+	}
+}
+
+func usage() {
+	fmt.Fprintf(flag.CommandLine.Output(), `Usage: %s [flags] [command]
+
+Commands:
+  run, (none)      sync bookmarks from Instapaper and write the feeds
+  sync             sync bookmarks from Instapaper into storage
+  build            write the feeds from storage, without Instapaper credentials
+  export           write stored bookmarks to JSON Lines
+  export-markdown  write stored bookmarks as Markdown notes
+  import           read bookmarks from JSON Lines into storage
+  epub             write stored bookmarks to an EPUB book
+  status           show recent runs and storage stats
+  validate         check feed files for problems
+
+Flags:
+`, os.Args[0])
+	flag.PrintDefaults()
+}
+
+// runApp syncs bookmarks, builds the feeds, or both for "run"
+// and no command.
+func runApp(cmd string) error {
+	storage, err := openStorage()
+	if err != nil {
+		return err
+	}
+	defer storage.Close()
+
+	feeds, err := parseFeeds(getEnvVar("FEEDS", ""))
+	if err != nil {
+		return err
+	}
+
+	outputs, err := createOutputs(feeds)
+	if err != nil {
+		return err
+	}
+
+	if cmd == "build" {
+		result, err := NewApp(nil, storage, outputs).Build()
+		if err != nil {
+			return fmt.Errorf("failed to build feeds: %w", err)
+		}
+		return writeOutputs(result)
+	}
+
 	client, err := createInstapaperClient()
 	if err != nil {
 		return fmt.Errorf("failed to create Instapaper client: %w", err)
 	}
 
-	storage, err := bolt.NewStorage(getEnvVar("STORAGE_PATH", "instapaper.db"))
+	processors, err := createProcessors()
 	if err != nil {
-		return fmt.Errorf("failed to create storage: %w", err)
+		return err
 	}
-	defer storage.Close()
 
-	newBookmarksCount, err := NewApp(client, storage, atom.FeedBuilder{}).
-		Run(getEnvVar("FEED_PATH", "feed.xml"))
+	app := NewApp(
+		client,
+		storage,
+		outputs,
+		WithFolders(feeds.folders()...),
+		WithProcessors(processors...),
+	)
+
+	appRun := app.Run
+	if cmd == "sync" {
+		appRun = app.Sync
+	}
+
+	result, err := appRun()
 	if err != nil {
 		return fmt.Errorf("failed to run app: %w", err)
 	}
 
-	// if run from GitHub Actions, set output variable
-	if os.Getenv("GITHUB_ACTIONS") == "true" {
-		if err := writeOutput("new_bookmarks_count", strconv.Itoa(newBookmarksCount)); err != nil {
+	return writeOutputs(result)
+}
+
+// writeOutputs sets the action outputs when run from GitHub Actions.
+func writeOutputs(result structs.Run) error {
+	if os.Getenv("GITHUB_ACTIONS") != "true" {
+		return nil
+	}
+
+	outputs := []struct{ key, value string }{
+		{"new_bookmarks_count", strconv.Itoa(result.NewBookmarks)},
+		{"changed_files", strings.Join(result.Files, "\n")},
+		{"feed_changed", strconv.FormatBool(result.FeedChanged)},
+	}
+	for _, o := range outputs {
+		if err := writeOutput(o.key, o.value); err != nil {
 			return fmt.Errorf("failed to write output: %w", err)
 		}
 	}
@@ -52,6 +152,14 @@ func run() error {
 	return nil
 }
 
+func openStorage() (*bolt.Storage, error) {
+	storage, err := bolt.NewStorage(getEnvVar("STORAGE_PATH", "instapaper.db"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create storage: %w", err)
+	}
+	return storage, nil
+}
+
 func getEnvVar(key, defaultValue string) string {
 	// Try regular environment variable
 	if val := os.Getenv(key); val != "" {
@@ -67,10 +175,6 @@ func getEnvVar(key, defaultValue string) string {
 }
 
 func createInstapaperClient() (*instapaper.Client, error) {
-	username := flag.String("username", "", "Instapaper username")
-	password := flag.String("password", "", "Instapaper password")
-	flag.Parse()
-
 	consumerKey := getEnvVar("INSTAPAPER_CONSUMER_KEY", "")
 	if consumerKey == "" {
 		return nil, fmt.Errorf("INSTAPAPER_CONSUMER_KEY environment variable is not set")
@@ -151,8 +255,8 @@ func formatOutput(name, value string) string {
 
 	// if value contains new line, use multiline format
 	if bytes.ContainsRune([]byte(value), '\n') {
-		return fmt.Sprintf("%s<<OUTPUT\n%s\nOUTPUT", name, value)
+		return fmt.Sprintf("%s<<OUTPUT\n%s\nOUTPUT\n", name, value)
 	}
 
-	return fmt.Sprintf("%s=%s", name, value)
+	return fmt.Sprintf("%s=%s\n", name, value)
 }
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/chuhlomin/instapaper2rss/pkg/structs"
+)
+
+// readOutputs parses a $GITHUB_OUTPUT file the way GitHub Actions does:
+// "name=value" records and "name<<DELIMITER" multiline records.
+func readOutputs(t *testing.T, path string) map[string]string {
+	t.Helper()
+
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+
+	outputs := map[string]string{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if name, delimiter, ok := strings.Cut(line, "<<"); ok {
+			var lines []string
+			closed := false
+			for scanner.Scan() {
+				if scanner.Text() == delimiter {
+					closed = true
+					break
+				}
+				lines = append(lines, scanner.Text())
+			}
+			require.True(t, closed, "delimiter %s of %s is not closed", delimiter, name)
+			outputs[name] = strings.Join(lines, "\n")
+			continue
+		}
+
+		name, value, ok := strings.Cut(line, "=")
+		require.True(t, ok, "invalid output line %q", line)
+		outputs[name] = value
+	}
+	require.NoError(t, scanner.Err())
+
+	return outputs
+}
+
+func setupGitHubOutput(t *testing.T) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "output")
+	require.NoError(t, os.WriteFile(path, nil, 0o644))
+	t.Setenv("GITHUB_ACTIONS", "true")
+	t.Setenv("GITHUB_OUTPUT", path)
+	return path
+}
+
+func TestWriteOutputs(t *testing.T) {
+	path := setupGitHubOutput(t)
+
+	require.NoError(t, writeOutputs(structs.Run{
+		NewBookmarks: 2,
+		Files:        []string{"feed.xml", "site/index.html"},
+		FeedChanged:  true,
+	}))
+
+	assert.Equal(t, map[string]string{
+		"new_bookmarks_count": "2",
+		"changed_files":       "feed.xml\nsite/index.html",
+		"feed_changed":        "true",
+	}, readOutputs(t, path))
+}
+
+func TestWriteOutputs_FeedChanged(t *testing.T) {
+	for _, changed := range []bool{true, false} {
+		path := setupGitHubOutput(t)
+
+		require.NoError(t, writeOutputs(structs.Run{FeedChanged: changed}))
+
+		outputs := readOutputs(t, path)
+		assert.Equal(t, strconv.FormatBool(changed), outputs["feed_changed"])
+		assert.Equal(t, "0", outputs["new_bookmarks_count"])
+		assert.NotContains(t, outputs, "changed_files")
+	}
+}
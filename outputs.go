@@ -0,0 +1,363 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/chuhlomin/instapaper2rss/pkg/atom"
+	"github.com/chuhlomin/instapaper2rss/pkg/entry"
+	"github.com/chuhlomin/instapaper2rss/pkg/epub"
+	"github.com/chuhlomin/instapaper2rss/pkg/jsonfeed"
+	"github.com/chuhlomin/instapaper2rss/pkg/precompress"
+	"github.com/chuhlomin/instapaper2rss/pkg/rss"
+	"github.com/chuhlomin/instapaper2rss/pkg/site"
+	"github.com/chuhlomin/instapaper2rss/pkg/structs"
+	"github.com/chuhlomin/instapaper2rss/pkg/validate"
+)
+
+// feedConfig is a feed limited to one folder, tag, language
+// or range of reading times.
+type feedConfig struct {
+	kind string // "folder", "tag", "lang" or "minutes"
+	name string
+	path string
+
+	minMinutes, maxMinutes int // for "minutes", 0 for no limit
+}
+
+type feedConfigs []feedConfig
+
+// parseFeeds parses FEEDS, one feed per line:
+//
+//	folder:Work=work.xml
+//	tag:Long reads=long-reads.xml
+//	lang:de=german.xml
+//	minutes:-10=quick.xml
+func parseFeeds(s string) (feedConfigs, error) {
+	var feeds feedConfigs
+
+	for i, line := range strings.Split(s, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		selector, path, ok := strings.Cut(line, "=")
+		kind, name, ok2 := strings.Cut(selector, ":")
+		kind, name, path = strings.TrimSpace(kind), strings.TrimSpace(name), strings.TrimSpace(path)
+		if !ok || !ok2 || name == "" || path == "" || !slices.Contains(feedKinds, kind) {
+			return nil, fmt.Errorf(
+				"invalid FEEDS line %d %q, expected \"<folder|tag|lang|minutes>:<name>=<path>\"",
+				i+1, line,
+			)
+		}
+
+		f := feedConfig{kind: kind, name: name, path: path}
+		if kind == "minutes" {
+			var err error
+			if f.minMinutes, f.maxMinutes, err = parseMinutes(name); err != nil {
+				return nil, fmt.Errorf("invalid FEEDS line %d %q: %w", i+1, line, err)
+			}
+		}
+
+		feeds = append(feeds, f)
+	}
+
+	return feeds, nil
+}
+
+var feedKinds = []string{"folder", "tag", "lang", "minutes"}
+
+// parseMinutes parses a range of reading times in minutes:
+// "-10" is up to 10 minutes, "30-" is 30 minutes or more
+// and "10-30" is between 10 and 30 minutes.
+func parseMinutes(s string) (minMinutes, maxMinutes int, err error) {
+	from, to, ok := strings.Cut(s, "-")
+	if !ok || from == "" && to == "" {
+		return 0, 0, fmt.Errorf("expected a range of minutes like \"-10\", \"10-30\" or \"30-\"")
+	}
+
+	for _, v := range []struct {
+		s string
+		n *int
+	}{{from, &minMinutes}, {to, &maxMinutes}} {
+		if v.s == "" {
+			continue
+		}
+		if *v.n, err = strconv.Atoi(strings.TrimSpace(v.s)); err != nil || *v.n < 1 {
+			return 0, 0, fmt.Errorf("invalid number of minutes %q", v.s)
+		}
+	}
+
+	if maxMinutes > 0 && minMinutes > maxMinutes {
+		return 0, 0, fmt.Errorf("%d is more than %d", minMinutes, maxMinutes)
+	}
+	return minMinutes, maxMinutes, nil
+}
+
+// folders returns titles of folders that have feeds.
+func (feeds feedConfigs) folders() []string {
+	var folders []string
+	for _, f := range feeds {
+		if f.kind == "folder" {
+			folders = append(folders, f.name)
+		}
+	}
+	return folders
+}
+
+// title returns the feed title suffix, e.g. "Work" or "up to 10 min".
+func (f feedConfig) title() string {
+	if f.kind != "minutes" {
+		return f.name
+	}
+
+	switch {
+	case f.minMinutes == 0:
+		return fmt.Sprintf("up to %d min", f.maxMinutes)
+	case f.maxMinutes == 0:
+		return fmt.Sprintf("%d min or more", f.minMinutes)
+	default:
+		return fmt.Sprintf("%d to %d min", f.minMinutes, f.maxMinutes)
+	}
+}
+
+func (f feedConfig) filter() func(structs.Bookmark) bool {
+	switch f.kind {
+	case "folder":
+		return func(b structs.Bookmark) bool {
+			return inFolder(b, f.name)
+		}
+
+	case "lang":
+		return func(b structs.Bookmark) bool {
+			return strings.EqualFold(b.Language, f.name)
+		}
+
+	case "minutes":
+		// bookmarks without a reading time have no text
+		return func(b structs.Bookmark) bool {
+			return b.ReadingTime > 0 &&
+				b.ReadingTime >= f.minMinutes &&
+				(f.maxMinutes == 0 || b.ReadingTime <= f.maxMinutes)
+		}
+	}
+
+	return func(b structs.Bookmark) bool {
+		for _, tag := range b.Tags {
+			if strings.EqualFold(tag, f.name) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+func createOutputs(feeds feedConfigs) ([]Output, error) {
+	format := getEnvVar("FEED_FORMAT", "atom")
+	title := getEnvVar("FEED_TITLE", "Instapaper")
+	feedURL := getEnvVar("FEED_URL", "")
+
+	var entryTemplate *entry.Template
+	if path := getEnvVar("FEED_ENTRY_TEMPLATE", ""); path != "" {
+		t, err := entry.ParseFile(path)
+		if err != nil {
+			return nil, err
+		}
+		entryTemplate = t
+	}
+
+	feedPath := getEnvVar("FEED_PATH", "feed.xml")
+	feedBuilder, err := createFeedBuilder(format, title, feedURL, feedPath, entryTemplate)
+	if err != nil {
+		return nil, err
+	}
+
+	encodings, err := precompress.Parse(getEnvVar("PRECOMPRESS", ""))
+	if err != nil {
+		return nil, fmt.Errorf("invalid PRECOMPRESS: %w", err)
+	}
+
+	outputs := []Output{
+		{
+			Builder:   feedBuilder,
+			Path:      feedPath,
+			Validate:  validate.FeedReader,
+			Encodings: encodings,
+		},
+	}
+
+	for _, f := range feeds {
+		builder, err := createFeedBuilder(format, title+": "+f.title(), siblingURL(feedURL, f.path), f.path, entryTemplate)
+		if err != nil {
+			return nil, err
+		}
+
+		outputs = append(outputs, Output{
+			Builder:   builder,
+			Path:      f.path,
+			Filter:    f.filter(),
+			Validate:  validate.FeedReader,
+			Encodings: encodings,
+		})
+	}
+
+	if period := getEnvVar("DIGEST", ""); period != "" {
+		if period != atom.Daily && period != atom.Weekly {
+			return nil, fmt.Errorf("invalid DIGEST %q, expected %q or %q", period, atom.Daily, atom.Weekly)
+		}
+
+		path := getEnvVar("DIGEST_PATH", "digest.xml")
+		outputs = append(outputs, Output{
+			Builder: atom.DigestBuilder{
+				FeedBuilder: atom.FeedBuilder{
+					ID:       atom.FeedID(siblingURL(feedURL, path), path),
+					Title:    title + " digest",
+					Subtitle: getEnvVar("FEED_SUBTITLE", ""),
+					SelfURL:  siblingURL(feedURL, path),
+					Author:   getEnvVar("FEED_AUTHOR", ""),
+					Icon:     getEnvVar("FEED_ICON", ""),
+				},
+				Period: period,
+			},
+			Path:      path,
+			Validate:  validate.FeedReader,
+			Encodings: encodings,
+		})
+	}
+
+	if path := getEnvVar("HIGHLIGHTS_PATH", ""); path != "" {
+		outputs = append(outputs, Output{
+			Builder: atom.HighlightsBuilder{
+				FeedBuilder: atom.FeedBuilder{
+					ID:      atom.FeedID(siblingURL(feedURL, path), path),
+					Title:   title + " highlights",
+					SelfURL: siblingURL(feedURL, path),
+					Author:  getEnvVar("FEED_AUTHOR", ""),
+					Icon:    getEnvVar("FEED_ICON", ""),
+				},
+			},
+			Path:      path,
+			Validate:  validate.FeedReader,
+			Encodings: encodings,
+		})
+	}
+
+	if dir := getEnvVar("SITE_DIR", ""); dir != "" {
+		pageSize, err := strconv.Atoi(getEnvVar("SITE_PAGE_SIZE", "50"))
+		if err != nil || pageSize < 1 {
+			return nil, fmt.Errorf("invalid SITE_PAGE_SIZE %q", getEnvVar("SITE_PAGE_SIZE", ""))
+		}
+
+		outputs = append(outputs, Output{
+			Builder: site.Builder{
+				Title:        title,
+				PageSize:     pageSize,
+				TemplatesDir: getEnvVar("SITE_TEMPLATES", ""),
+			},
+			Path:      dir,
+			Encodings: encodings,
+		})
+	}
+
+	// EPUBs are ZIP archives, compressing them again saves nothing
+	if path := getEnvVar("EPUB_PATH", ""); path != "" {
+		outputs = append(outputs, Output{
+			Builder: createEPUBBuilder(title),
+			Path:    path,
+		})
+	}
+
+	if path := getEnvVar("JSON_FEED_PATH", ""); path != "" {
+		outputs = append(outputs, Output{
+			Builder: jsonfeed.FeedBuilder{
+				Title:   title,
+				FeedURL: getEnvVar("JSON_FEED_URL", ""),
+				Entry:   entryTemplate,
+			},
+			Path:      path,
+			Encodings: encodings,
+		})
+	}
+
+	return outputs, nil
+}
+
+func createFeedBuilder(format, title, selfURL, path string, entryTemplate *entry.Template) (FeedBuilder, error) {
+	switch format {
+	case "atom":
+		fb := atom.FeedBuilder{
+			ID:       atom.FeedID(selfURL, path),
+			Title:    title,
+			Subtitle: getEnvVar("FEED_SUBTITLE", ""),
+			SelfURL:  selfURL,
+			Author:   getEnvVar("FEED_AUTHOR", ""),
+			Icon:     getEnvVar("FEED_ICON", ""),
+			Entry:    entryTemplate,
+		}
+
+		pageSize := getEnvVar("FEED_PAGE_SIZE", "")
+		if pageSize == "" {
+			return fb, nil
+		}
+
+		n, err := strconv.Atoi(pageSize)
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("invalid FEED_PAGE_SIZE %q", pageSize)
+		}
+
+		// archive links must be absolute
+		if selfURL == "" {
+			return nil, fmt.Errorf("FEED_PAGE_SIZE requires FEED_URL")
+		}
+
+		return atom.ArchiveBuilder{FeedBuilder: fb, PageSize: n}, nil
+
+	case "rss":
+		if getEnvVar("FEED_PAGE_SIZE", "") != "" {
+			return nil, fmt.Errorf("FEED_PAGE_SIZE is only supported for Atom feeds")
+		}
+
+		return rss.FeedBuilder{
+			Title:       title,
+			Description: getEnvVar("FEED_SUBTITLE", ""),
+			SelfURL:     selfURL,
+			Entry:       entryTemplate,
+		}, nil
+
+	default:
+		return nil, fmt.Errorf("unknown feed format %q, expected \"atom\" or \"rss\"", format)
+	}
+}
+
+// siblingURL returns the URL of the file at path published
+// next to the feed at feedURL, or an empty string if feedURL is not set.
+func siblingURL(feedURL, path string) string {
+	if feedURL == "" {
+		return ""
+	}
+
+	u, err := url.Parse(feedURL)
+	if err != nil {
+		return ""
+	}
+
+	return u.ResolveReference(&url.URL{Path: filepath.Base(path)}).String()
+}
+
+// createEPUBBuilder returns an EPUB builder that embeds
+// images downloaded to IMAGES_DIR.
+func createEPUBBuilder(title string) epub.Builder {
+	dir := getEnvVar("IMAGES_DIR", "")
+
+	return epub.Builder{
+		Title:     title,
+		Author:    getEnvVar("FEED_AUTHOR", ""),
+		ImagesDir: dir,
+		ImagesURL: imagesURL(dir),
+	}
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/chuhlomin/instapaper2rss/pkg/atom"
+	"github.com/chuhlomin/instapaper2rss/pkg/structs"
+)
+
+func TestParseFeeds(t *testing.T) {
+	feeds, err := parseFeeds(`
+folder:Work=work.xml
+# comment
+tag: Long reads = long-reads.xml
+`)
+	assert.NoError(t, err)
+	assert.Equal(t, feedConfigs{
+		{kind: "folder", name: "Work", path: "work.xml"},
+		{kind: "tag", name: "Long reads", path: "long-reads.xml"},
+	}, feeds)
+	assert.Equal(t, []string{"Work"}, feeds.folders())
+
+	assert.True(t, feeds[0].filter()(structs.Bookmark{Folder: "work"}))
+	assert.False(t, feeds[0].filter()(structs.Bookmark{Folder: "unread"}))
+	assert.True(t, feeds[1].filter()(structs.Bookmark{Tags: []string{"go", "long reads"}}))
+	assert.False(t, feeds[1].filter()(structs.Bookmark{Tags: []string{"go"}}))
+
+	_, err = parseFeeds("label:Work=work.xml")
+	assert.ErrorContains(t, err, `invalid FEEDS line 1 "label:Work=work.xml"`)
+
+	_, err = parseFeeds("folder:Work")
+	assert.ErrorContains(t, err, "invalid FEEDS line 1")
+}
+
+func TestParseFeeds_LanguageAndMinutes(t *testing.T) {
+	feeds, err := parseFeeds(`
+lang:de=german.xml
+minutes:-10=quick.xml
+minutes:10-30=medium.xml
+minutes:30-=long.xml
+`)
+	require.NoError(t, err)
+	assert.Equal(t, feedConfigs{
+		{kind: "lang", name: "de", path: "german.xml"},
+		{kind: "minutes", name: "-10", path: "quick.xml", maxMinutes: 10},
+		{kind: "minutes", name: "10-30", path: "medium.xml", minMinutes: 10, maxMinutes: 30},
+		{kind: "minutes", name: "30-", path: "long.xml", minMinutes: 30},
+	}, feeds)
+	assert.Empty(t, feeds.folders())
+
+	assert.Equal(t, "de", feeds[0].title())
+	assert.Equal(t, "up to 10 min", feeds[1].title())
+	assert.Equal(t, "10 to 30 min", feeds[2].title())
+	assert.Equal(t, "30 min or more", feeds[3].title())
+
+	assert.True(t, feeds[0].filter()(structs.Bookmark{Language: "de"}))
+	assert.False(t, feeds[0].filter()(structs.Bookmark{Language: "en"}))
+	assert.True(t, feeds[1].filter()(structs.Bookmark{ReadingTime: 10}))
+	assert.False(t, feeds[1].filter()(structs.Bookmark{ReadingTime: 11}))
+	assert.False(t, feeds[1].filter()(structs.Bookmark{}))
+	assert.True(t, feeds[2].filter()(structs.Bookmark{ReadingTime: 30}))
+	assert.False(t, feeds[2].filter()(structs.Bookmark{ReadingTime: 9}))
+	assert.True(t, feeds[3].filter()(structs.Bookmark{ReadingTime: 45}))
+
+	for _, line := range []string{"minutes:-=x.xml", "minutes:10=x.xml", "minutes:a-=x.xml", "minutes:30-10=x.xml", "minutes:0-10=x.xml"} {
+		_, err = parseFeeds(line)
+		assert.ErrorContains(t, err, "invalid FEEDS line 1", line)
+	}
+}
+
+func TestSiblingURL(t *testing.T) {
+	assert.Equal(t, "https://example.com/feeds/work.xml", siblingURL("https://example.com/feeds/atom.xml", "out/work.xml"))
+	assert.Equal(t, "", siblingURL("", "work.xml"))
+}
+
+func TestCreateOutputs_Digest(t *testing.T) {
+	t.Setenv("FEED_URL", "https://example.com/atom.xml")
+	t.Setenv("DIGEST", "week")
+
+	outputs, err := createOutputs(nil)
+	require.NoError(t, err)
+	require.Len(t, outputs, 2)
+	assert.Equal(t, "digest.xml", outputs[1].Path)
+	assert.Equal(t, atom.DigestBuilder{
+		FeedBuilder: atom.FeedBuilder{
+			ID:      "https://example.com/digest.xml",
+			Title:   "Instapaper digest",
+			SelfURL: "https://example.com/digest.xml",
+		},
+		Period: atom.Weekly,
+	}, outputs[1].Builder)
+
+	t.Setenv("DIGEST", "month")
+	_, err = createOutputs(nil)
+	assert.EqualError(t, err, `invalid DIGEST "month", expected "day" or "week"`)
+}
+
+func TestCreateOutputs_EntryTemplate(t *testing.T) {
+	t.Setenv("FEED_ENTRY_TEMPLATE", "pkg/entry/testdata/header.html")
+
+	outputs, err := createOutputs(nil)
+	require.NoError(t, err)
+	require.NotNil(t, outputs[0].Builder.(atom.FeedBuilder).Entry)
+
+	t.Setenv("FEED_ENTRY_TEMPLATE", "missing.html")
+	_, err = createOutputs(nil)
+	assert.ErrorContains(t, err, "error reading entry template")
+}
+
+func TestCreateOutputs_Highlights(t *testing.T) {
+	t.Setenv("FEED_URL", "https://example.com/atom.xml")
+	t.Setenv("HIGHLIGHTS_PATH", "public/highlights.xml")
+
+	outputs, err := createOutputs(nil)
+	require.NoError(t, err)
+	require.Len(t, outputs, 2)
+	assert.Equal(t, "public/highlights.xml", outputs[1].Path)
+	assert.Equal(t, atom.HighlightsBuilder{
+		FeedBuilder: atom.FeedBuilder{
+			ID:      "https://example.com/highlights.xml",
+			Title:   "Instapaper highlights",
+			SelfURL: "https://example.com/highlights.xml",
+		},
+	}, outputs[1].Builder)
+}
+
+func TestCreateOutputs_FeedIDs(t *testing.T) {
+	feeds, err := parseFeeds("folder:Work=work.xml")
+	require.NoError(t, err)
+
+	for _, feedURL := range []string{"", "https://example.com/atom.xml"} {
+		t.Setenv("FEED_URL", feedURL)
+
+		outputs, err := createOutputs(feeds)
+		require.NoError(t, err)
+		require.Len(t, outputs, 2)
+
+		main := outputs[0].Builder.(atom.FeedBuilder).ID
+		work := outputs[1].Builder.(atom.FeedBuilder).ID
+		assert.NotEmpty(t, main)
+		assert.NotEqual(t, main, work)
+	}
+}
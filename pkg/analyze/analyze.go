@@ -0,0 +1,193 @@
+package analyze
+
+import (
+	"embed"
+	"path"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/chuhlomin/instapaper2rss/pkg/htmltext"
+	"github.com/chuhlomin/instapaper2rss/pkg/structs"
+)
+
+const (
+	// WordsPerMinute is the reading speed used for reading time estimates.
+	WordsPerMinute = 230
+
+	// minWords is the shortest text to detect the language of.
+	minWords = 10
+
+	// profileSize is the number of most frequent n-grams compared.
+	profileSize = 300
+)
+
+// Result is what Text finds out about an article.
+type Result struct {
+	Words       int
+	ReadingTime int    // estimated minutes, at least 1 for non-empty text
+	Language    string // ISO 639-1 code, empty when unknown
+}
+
+// Text analyzes article HTML: counts words, estimates the reading time
+// and detects the language.
+func Text(s string) Result {
+	text := htmltext.PlainText(s)
+	words := len(strings.Fields(text))
+
+	return Result{
+		Words:       words,
+		ReadingTime: ReadingTime(words),
+		Language:    Language(text),
+	}
+}
+
+// Bookmark saves the analysis of the bookmark text on it.
+func Bookmark(b *structs.Bookmark) {
+	result := Text(b.Text)
+	b.Words = result.Words
+	b.ReadingTime = result.ReadingTime
+	b.Language = result.Language
+}
+
+// ReadingTime returns the estimated minutes to read the words.
+func ReadingTime(words int) int {
+	return (words + WordsPerMinute - 1) / WordsPerMinute
+}
+
+//go:embed profiles/*.txt
+var profilesFS embed.FS
+
+// profiles are n-gram ranks by language, built from sample texts.
+var profiles = loadProfiles()
+
+func loadProfiles() map[string]map[string]int {
+	entries, err := profilesFS.ReadDir("profiles")
+	if err != nil {
+		panic(err)
+	}
+
+	result := make(map[string]map[string]int, len(entries))
+	for _, e := range entries {
+		b, err := profilesFS.ReadFile("profiles/" + e.Name())
+		if err != nil {
+			panic(err)
+		}
+		lang := strings.TrimSuffix(e.Name(), path.Ext(e.Name()))
+		result[lang] = profile(string(b))
+	}
+	return result
+}
+
+// Language detects the language of plain text. Japanese, Korean and
+// Chinese are told by their scripts, other languages by comparing
+// n-gram frequencies with the embedded profiles (Cavnar and Trenkle).
+// It returns an empty string for short texts.
+func Language(text string) string {
+	if lang := scriptLanguage(text); lang != "" {
+		return lang
+	}
+
+	if len(strings.Fields(text)) < minWords {
+		return ""
+	}
+
+	ranks := profile(text)
+	if len(ranks) == 0 {
+		return ""
+	}
+
+	best, bestDistance := "", -1
+	for lang, p := range profiles {
+		distance := 0
+		for ngram, rank := range ranks {
+			r, ok := p[ngram]
+			if !ok {
+				distance += profileSize
+				continue
+			}
+			distance += abs(rank - r)
+		}
+
+		if bestDistance < 0 || distance < bestDistance || distance == bestDistance && lang < best {
+			best, bestDistance = lang, distance
+		}
+	}
+
+	return best
+}
+
+// scriptLanguage returns the language of texts mostly written
+// in kana, hangul or han characters.
+func scriptLanguage(text string) string {
+	var letters, kana, hangul, han int
+	for _, r := range text {
+		switch {
+		case unicode.In(r, unicode.Hiragana, unicode.Katakana):
+			kana++
+		case unicode.Is(unicode.Hangul, r):
+			hangul++
+		case unicode.Is(unicode.Han, r):
+			han++
+		case !unicode.IsLetter(r):
+			continue
+		}
+		letters++
+	}
+
+	if letters == 0 || (kana+hangul+han)*2 < letters {
+		return ""
+	}
+
+	switch {
+	case kana > 0:
+		return "ja"
+	case hangul > han:
+		return "ko"
+	default:
+		return "zh"
+	}
+}
+
+// profile returns the ranks of the most frequent 1- to 3-grams of text,
+// counted within words padded with spaces.
+func profile(text string) map[string]int {
+	counts := map[string]int{}
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
+	for _, word := range words {
+		runes := []rune(" " + word + " ")
+		for n := 1; n <= 3; n++ {
+			for i := 0; i+n <= len(runes); i++ {
+				if ngram := string(runes[i : i+n]); ngram != " " {
+					counts[ngram]++
+				}
+			}
+		}
+	}
+
+	ngrams := make([]string, 0, len(counts))
+	for ngram := range counts {
+		ngrams = append(ngrams, ngram)
+	}
+	sort.Slice(ngrams, func(i, j int) bool {
+		if counts[ngrams[i]] != counts[ngrams[j]] {
+			return counts[ngrams[i]] > counts[ngrams[j]]
+		}
+		return ngrams[i] < ngrams[j]
+	})
+
+	ranks := make(map[string]int, min(len(ngrams), profileSize))
+	for i, ngram := range ngrams[:min(len(ngrams), profileSize)] {
+		ranks[ngram] = i
+	}
+	return ranks
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,49 @@
+package analyze
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLanguage(t *testing.T) {
+	// sentences that are not in the profiles
+	tests := []struct {
+		text     string
+		expected string
+	}{
+		{"The city council voted on Tuesday to close the old bridge for repairs, which will take most of the summer.", "en"},
+		{"Der Stadtrat hat am Dienstag beschlossen, die alte Brücke für Reparaturen zu schließen, was den größten Teil des Sommers dauern wird.", "de"},
+		{"Le conseil municipal a voté mardi la fermeture du vieux pont pour des réparations qui dureront presque tout l'été.", "fr"},
+		{"El ayuntamiento votó el martes cerrar el puente viejo por reparaciones, que durarán casi todo el verano.", "es"},
+		{"Il consiglio comunale ha votato martedì per chiudere il vecchio ponte per le riparazioni, che dureranno quasi tutta l'estate.", "it"},
+		{"A câmara municipal votou na terça-feira para fechar a velha ponte para reparos, que vão durar quase todo o verão.", "pt"},
+		{"De gemeenteraad heeft dinsdag besloten de oude brug te sluiten voor reparaties, die het grootste deel van de zomer zullen duren.", "nl"},
+		{"Kommunfullmäktige röstade i tisdags för att stänga den gamla bron för reparationer, som kommer att ta större delen av sommaren.", "sv"},
+		{"Rada miasta zagłosowała we wtorek za zamknięciem starego mostu na czas remontu, który potrwa przez większą część lata.", "pl"},
+		{"Городской совет во вторник проголосовал за закрытие старого моста на ремонт, который продлится большую часть лета.", "ru"},
+		{"Міська рада у вівторок проголосувала за закриття старого мосту на ремонт, який триватиме більшу частину літа.", "uk"},
+		{"市議会は火曜日、古い橋を修理のために閉鎖することを決めました。", "ja"},
+		{"시의회는 화요일에 오래된 다리를 수리하기 위해 폐쇄하기로 결정했다.", "ko"},
+		{"市议会周二投票决定关闭旧桥进行维修，工程将持续整个夏天。", "zh"},
+		{"Too short to tell.", ""},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expected, func(t *testing.T) {
+			assert.Equal(t, tt.expected, Language(tt.text))
+		})
+	}
+}
+
+func TestText(t *testing.T) {
+	result := Text("<h1>Title</h1><p>" + strings.Repeat("one two three four five ", 100) + "</p><script>var x = 1;</script>")
+	assert.Equal(t, Result{Words: 501, ReadingTime: 3, Language: "en"}, result)
+
+	assert.Equal(t, Result{}, Text(""))
+	assert.Equal(t, 1, ReadingTime(1))
+	assert.Equal(t, 1, ReadingTime(WordsPerMinute))
+	assert.Equal(t, 2, ReadingTime(WordsPerMinute+1))
+}
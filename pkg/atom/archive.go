@@ -0,0 +1,162 @@
+package atom
+
+import (
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/chuhlomin/instapaper2rss/pkg/structs"
+)
+
+const historyNamespace = "http://purl.org/syndication/history/1.0"
+
+// ArchiveBuilder splits the feed into a subscription document
+// and monthly archive documents, following RFC 5005 "Archived Feeds".
+//
+// The subscription document holds the latest PageSize entries,
+// or all entries of the newest month if there are more of them.
+// Every earlier month gets an archive next to the subscription document,
+// e.g. "atom-2025-03.xml" for "atom.xml". Archives only depend on their own
+// entries and neighbours, so they don't change once a newer month exists.
+type ArchiveBuilder struct {
+	FeedBuilder
+	PageSize int
+}
+
+type month struct {
+	key       string // "2006-01"
+	bookmarks []structs.Bookmark
+}
+
+func (ab ArchiveBuilder) BuildFiles(path string, bookmarks []structs.Bookmark) ([]structs.File, error) {
+	sorted := sortNewestFirst(bookmarks)
+	months := groupByMonth(sorted)
+
+	var (
+		current  []structs.Bookmark
+		archives []month // oldest first
+	)
+	if len(months) > 0 {
+		current = months[0].bookmarks
+		archives = months[1:]
+		reverse(archives)
+	}
+	if len(current) < ab.PageSize {
+		current = sorted[:min(ab.PageSize, len(sorted))]
+	}
+
+	files := make([]structs.File, 0, len(archives)+1)
+
+	feed, err := ab.newFeed(current)
+	if err != nil {
+		return nil, err
+	}
+	if ab.SelfURL != "" {
+		feed.Link = append(feed.Link, ab.link("self", filepath.Base(path)))
+	}
+	if len(archives) > 0 {
+		feed.Link = append(feed.Link, ab.link("prev-archive", archiveName(path, archives[len(archives)-1].key)))
+	}
+
+	b, err := marshal(feed)
+	if err != nil {
+		return nil, fmt.Errorf("subscription document: %w", err)
+	}
+	files = append(files, structs.File{Path: path, Data: b})
+
+	for i, m := range archives {
+		name := archiveName(path, m.key)
+
+		feed, err := ab.newFeed(m.bookmarks)
+		if err != nil {
+			return nil, err
+		}
+		feed.XmlnsFH = historyNamespace
+		feed.Archive = &Archive{}
+		feed.Link = append(feed.Link,
+			ab.link("self", name),
+			ab.link("current", filepath.Base(path)),
+		)
+		if i > 0 {
+			feed.Link = append(feed.Link, ab.link("prev-archive", archiveName(path, archives[i-1].key)))
+		}
+		if i < len(archives)-1 {
+			feed.Link = append(feed.Link, ab.link("next-archive", archiveName(path, archives[i+1].key)))
+		}
+
+		b, err := marshal(feed)
+		if err != nil {
+			return nil, fmt.Errorf("archive document %s: %w", m.key, err)
+		}
+		files = append(files, structs.File{Path: filepath.Join(filepath.Dir(path), name), Data: b})
+	}
+
+	return files, nil
+}
+
+// link returns a link to a document next to the feed,
+// absolute when the feed URL is known.
+func (ab ArchiveBuilder) link(rel, name string) Link {
+	href := name
+	if base, err := url.Parse(ab.SelfURL); err == nil && ab.SelfURL != "" {
+		href = base.ResolveReference(&url.URL{Path: name}).String()
+	}
+
+	return Link{
+		Rel:  rel,
+		Href: href,
+		Type: "application/atom+xml",
+	}
+}
+
+// archiveName returns the file name of the archive for the month,
+// e.g. "atom-2025-03.xml" for "path/to/atom.xml".
+func archiveName(path, month string) string {
+	base := filepath.Base(path)
+	ext := filepath.Ext(base)
+	return strings.TrimSuffix(base, ext) + "-" + month + ext
+}
+
+func sortNewestFirst(bookmarks []structs.Bookmark) []structs.Bookmark {
+	sorted := make([]structs.Bookmark, len(bookmarks))
+	copy(sorted, bookmarks)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].Time != sorted[j].Time {
+			return sorted[i].Time > sorted[j].Time
+		}
+		return sorted[i].ID > sorted[j].ID
+	})
+	return sorted
+}
+
+// groupByMonth groups bookmarks sorted newest first by UTC month,
+// newest month first.
+func groupByMonth(sorted []structs.Bookmark) []month {
+	var months []month
+	for _, b := range sorted {
+		key := time.Unix(b.Time, 0).UTC().Format("2006-01")
+		if len(months) == 0 || months[len(months)-1].key != key {
+			months = append(months, month{key: key})
+		}
+		months[len(months)-1].bookmarks = append(months[len(months)-1].bookmarks, b)
+	}
+	return months
+}
+
+// newestUpdated returns the time of the newest bookmark in RFC 3339,
+// so documents don't change between runs without new entries.
+func newestUpdated(sorted []structs.Bookmark, fallback string) string {
+	if len(sorted) == 0 {
+		return fallback
+	}
+	return time.Unix(sorted[0].Time, 0).Format(time.RFC3339)
+}
+
+func reverse(months []month) {
+	for i, j := 0, len(months)-1; i < j; i, j = i+1, j-1 {
+		months[i], months[j] = months[j], months[i]
+	}
+}
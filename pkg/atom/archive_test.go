@@ -0,0 +1,92 @@
+package atom
+
+import (
+	"encoding/xml"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/chuhlomin/instapaper2rss/pkg/structs"
+)
+
+func TestArchiveBuilder_BuildFiles(t *testing.T) {
+	at := func(month time.Month, day int) int64 {
+		return time.Date(2025, month, day, 12, 0, 0, 0, time.UTC).Unix()
+	}
+
+	bookmarks := []structs.Bookmark{
+		{ID: 1, Time: at(time.January, 10)},
+		{ID: 2, Time: at(time.January, 20)},
+		{ID: 3, Time: at(time.February, 1)},
+		{ID: 4, Time: at(time.March, 5)},
+		{ID: 5, Time: at(time.March, 6)},
+	}
+
+	ab := ArchiveBuilder{
+		FeedBuilder: FeedBuilder{SelfURL: "https://example.com/feeds/atom.xml"},
+		PageSize:    3,
+	}
+
+	files, err := ab.BuildFiles("out/atom.xml", bookmarks)
+	require.NoError(t, err)
+	require.Len(t, files, 3)
+
+	parse := func(f structs.File) (Atom, []string, map[string]string) {
+		var feed Atom
+		require.NoError(t, xml.Unmarshal(f.Data, &feed))
+
+		var ids []string
+		for _, e := range feed.Entry {
+			ids = append(ids, e.ID)
+		}
+
+		links := map[string]string{}
+		for _, l := range feed.Link {
+			links[l.Rel] = l.Href
+		}
+		return feed, ids, links
+	}
+
+	assert.Equal(t, "out/atom.xml", files[0].Path)
+	feed, ids, links := parse(files[0])
+	assert.Equal(t, []string{
+		"tag:instapaper.com,2025-03-06:bookmark/5",
+		"tag:instapaper.com,2025-03-05:bookmark/4",
+		"tag:instapaper.com,2025-02-01:bookmark/3",
+	}, ids)
+	assert.Equal(t, map[string]string{
+		"self":         "https://example.com/feeds/atom.xml",
+		"prev-archive": "https://example.com/feeds/atom-2025-02.xml",
+	}, links)
+	assert.NotContains(t, string(files[0].Data), "fh:archive")
+	assert.Equal(t, time.Unix(at(time.March, 6), 0).Format(time.RFC3339), feed.Updated)
+
+	assert.Equal(t, "out/atom-2025-01.xml", files[1].Path)
+	_, ids, links = parse(files[1])
+	assert.Len(t, ids, 2)
+	assert.Equal(t, map[string]string{
+		"self":         "https://example.com/feeds/atom-2025-01.xml",
+		"current":      "https://example.com/feeds/atom.xml",
+		"next-archive": "https://example.com/feeds/atom-2025-02.xml",
+	}, links)
+	assert.Contains(t, string(files[1].Data), `xmlns:fh="http://purl.org/syndication/history/1.0"`)
+	assert.Contains(t, string(files[1].Data), `<fh:archive></fh:archive>`)
+
+	assert.Equal(t, "out/atom-2025-02.xml", files[2].Path)
+	_, ids, links = parse(files[2])
+	assert.Len(t, ids, 1)
+	assert.Equal(t, map[string]string{
+		"self":         "https://example.com/feeds/atom-2025-02.xml",
+		"current":      "https://example.com/feeds/atom.xml",
+		"prev-archive": "https://example.com/feeds/atom-2025-01.xml",
+	}, links)
+
+	// archives don't change when new entries arrive in the current month
+	again, err := ab.BuildFiles("out/atom.xml", append(bookmarks, structs.Bookmark{ID: 6, Time: at(time.March, 7)}))
+	require.NoError(t, err)
+	require.Len(t, again, 3)
+	assert.Equal(t, files[1], again[1])
+	assert.Equal(t, files[2], again[2])
+}
@@ -1,21 +1,51 @@
 package atom
 
 import (
+	"bytes"
+	"cmp"
 	"encoding/xml"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"path/filepath"
 	"strconv"
+	"strings"
 	"time"
 
+	"github.com/chuhlomin/instapaper2rss/pkg/entry"
+	"github.com/chuhlomin/instapaper2rss/pkg/htmltext"
 	"github.com/chuhlomin/instapaper2rss/pkg/structs"
+	"github.com/chuhlomin/instapaper2rss/pkg/xmltext"
 )
 
 type Atom struct {
-	XMLName xml.Name `xml:"feed"`
-	Xmlns   string   `xml:"xmlns,attr"`
-	Title   string   `xml:"title"`
-	ID      string   `xml:"id"`
-	Updated string   `xml:"updated"`
-	Link    []Link   `xml:"link"`
-	Entry   []Entry  `xml:"entry"`
+	XMLName    xml.Name  `xml:"feed"`
+	Xmlns      string    `xml:"xmlns,attr"`
+	XmlnsFH    string    `xml:"xmlns:fh,attr,omitempty"`
+	XmlnsMedia string    `xml:"xmlns:media,attr,omitempty"`
+	Archive    *Archive  `xml:"fh:archive"`
+	Title      string    `xml:"title"`
+	Subtitle   string    `xml:"subtitle,omitempty"`
+	ID         string    `xml:"id"`
+	Updated    string    `xml:"updated"`
+	Author     Author    `xml:"author"`
+	Generator  Generator `xml:"generator"`
+	Icon       string    `xml:"icon,omitempty"`
+	Link       []Link    `xml:"link"`
+	Entry      []Entry   `xml:"entry"`
+}
+
+// Archive marks an archive document (RFC 5005).
+type Archive struct{}
+
+type Author struct {
+	Name string `xml:"name"`
+}
+
+type Generator struct {
+	URI   string `xml:"uri,attr"`
+	Value string `xml:",chardata"`
 }
 
 type Link struct {
@@ -25,11 +55,26 @@ type Link struct {
 }
 
 type Entry struct {
-	Title   string  `xml:"title"`
-	Link    Link    `xml:"link"`
-	ID      string  `xml:"id"`
-	Updated string  `xml:"updated"`
-	Summary Summary `xml:"summary"`
+	Lang      string     `xml:"xml:lang,attr,omitempty"`
+	Title     string     `xml:"title"`
+	Link      []Link     `xml:"link"`
+	ID        string     `xml:"id"`
+	Updated   string     `xml:"updated"`
+	Published string     `xml:"published,omitempty"`
+	Author    *Author    `xml:"author"`
+	Category  []Category `xml:"category"`
+	Summary   *Summary   `xml:"summary"`
+	Content   Content    `xml:"content"`
+	Thumbnail *Thumbnail `xml:"media:thumbnail"`
+}
+
+// Thumbnail is a Media RSS thumbnail of the entry.
+type Thumbnail struct {
+	URL string `xml:"url,attr"`
+}
+
+type Category struct {
+	Term string `xml:"term,attr"`
 }
 
 type Summary struct {
@@ -37,31 +82,236 @@ type Summary struct {
 	Body string `xml:",chardata"`
 }
 
-type FeedBuilder struct{}
+type Content struct {
+	Type  string `xml:"type,attr"`
+	Body  string `xml:",chardata"`
+	XHTML *XHTML `xml:"div"`
+}
+
+// XHTML wraps well-formed article markup for type="xhtml" content.
+type XHTML struct {
+	Xmlns string `xml:"xmlns,attr"`
+	Body  string `xml:",innerxml"`
+}
+
+const (
+	defaultTitle   = "Instapaper"
+	defaultAuthor  = "Instapaper"
+	feedID         = "https://github.com/chuhlomin/instapaper2rss"
+	mediaNamespace = "http://search.yahoo.com/mrss/"
+
+	// emptyUpdated is the updated date of feeds without entries.
+	emptyUpdated = "1970-01-01T00:00:00Z"
+)
+
+// FeedBuilder builds an Atom feed (RFC 4287).
+// Empty fields fall back to defaults; without SelfURL
+// the feed has no rel="self" link, without Entry
+// the entry content is the article text.
+// Feeds must have unique IDs, see FeedID.
+type FeedBuilder struct {
+	ID       string
+	Title    string
+	Subtitle string
+	SelfURL  string
+	Author   string
+	Icon     string
+	Entry    *entry.Template
+}
 
 func (fb FeedBuilder) Build(bookmarks []structs.Bookmark) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := fb.BuildTo(&buf, bookmarks); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// BuildTo writes the feed to w as it is encoded.
+func (fb FeedBuilder) BuildTo(w io.Writer, bookmarks []structs.Bookmark) error {
+	feed, err := fb.newFeed(bookmarks)
+	if err != nil {
+		return err
+	}
+
+	if fb.SelfURL != "" {
+		feed.Link = append(feed.Link, Link{
+			Rel:  "self",
+			Href: xmltext.Clean(fb.SelfURL),
+			Type: "application/atom+xml",
+		})
+	}
+
+	return encode(w, feed)
+}
+
+// newFeed returns the feed with entries for bookmarks, newest first,
+// and no links. The feed is updated when its newest entry was,
+// so the same bookmarks always produce the same document.
+func (fb FeedBuilder) newFeed(bookmarks []structs.Bookmark) (Atom, error) {
+	bookmarks = sortNewestFirst(bookmarks)
+
 	feed := Atom{
-		Xmlns:   "http://www.w3.org/2005/Atom",
-		Title:   "Instapaper",
-		ID:      "https://github.com/chuhlomin/instapaper2rss",
-		Updated: time.Now().Format(time.RFC3339),
-		Entry:   make([]Entry, len(bookmarks)),
+		Xmlns:    "http://www.w3.org/2005/Atom",
+		Title:    xmltext.Clean(fb.Title),
+		Subtitle: xmltext.Clean(fb.Subtitle),
+		ID:       cmp.Or(xmltext.Clean(fb.ID), feedID),
+		Updated:  newestUpdated(bookmarks, emptyUpdated),
+		Author:   Author{Name: xmltext.Clean(fb.Author)},
+		Generator: Generator{
+			URI:   "https://github.com/chuhlomin/instapaper2rss",
+			Value: "instapaper2rss",
+		},
+		Icon:  xmltext.Clean(fb.Icon),
+		Entry: make([]Entry, len(bookmarks)),
+	}
+
+	if feed.Title == "" {
+		feed.Title = defaultTitle
+	}
+
+	if feed.Author.Name == "" {
+		feed.Author.Name = defaultAuthor
 	}
 
 	for i, b := range bookmarks {
-		feed.Entry[i] = Entry{
-			Title: b.Title,
-			Link: Link{
-				Href: b.URL,
+		b = xmltext.Bookmark(b)
+		content, err := fb.Entry.Render(b)
+		if err != nil {
+			return Atom{}, err
+		}
+
+		entry := Entry{
+			Lang:  b.Language,
+			Title: b.FeedTitle(),
+			Link: []Link{
+				{Href: b.URL},
 			},
-			ID:      strconv.Itoa(b.ID),
+			ID:      EntryID(b),
 			Updated: time.Unix(b.Time, 0).Format(time.RFC3339),
-			Summary: Summary{
-				Type: "html",
-				Body: b.Text,
+			Content: newContent(xmltext.Clean(content)),
+		}
+
+		if b.Published != 0 {
+			entry.Published = time.Unix(b.Published, 0).Format(time.RFC3339)
+		}
+
+		// publications without bylines are credited to the site
+		if author := cmp.Or(b.Byline, b.SiteName); author != "" {
+			entry.Author = &Author{Name: author}
+		}
+
+		if b.Image != "" {
+			feed.XmlnsMedia = mediaNamespace
+			entry.Thumbnail = &Thumbnail{URL: b.Image}
+			entry.Link = append(entry.Link, Link{
+				Rel:  "enclosure",
+				Href: b.Image,
+				Type: imageType(b.Image),
+			})
+		}
+
+		if summary := b.FeedSummary(); summary != "" {
+			entry.Summary = &Summary{
+				Type: "text",
+				Body: summary,
+			}
+		}
+
+		for _, tag := range b.Tags {
+			entry.Category = append(entry.Category, Category{Term: tag})
+		}
+
+		feed.Entry[i] = entry
+	}
+
+	return feed, nil
+}
+
+func marshal(feed Atom) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := encode(&buf, feed); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func encode(w io.Writer, feed Atom) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	return enc.Encode(feed)
+}
+
+// newContent embeds the article as XHTML when it is well-formed,
+// and as escaped HTML otherwise.
+func newContent(text string) Content {
+	if text != "" && htmltext.IsXHTML(text) {
+		return Content{
+			Type: "xhtml",
+			XHTML: &XHTML{
+				Xmlns: "http://www.w3.org/1999/xhtml",
+				Body:  text,
 			},
 		}
 	}
 
-	return xml.MarshalIndent(feed, "", "  ")
+	return Content{
+		Type: "html",
+		Body: text,
+	}
+}
+
+// imageType guesses the media type of the image from its URL,
+// so readers can tell the enclosure is an image.
+func imageType(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+
+	switch strings.ToLower(path.Ext(u.Path)) {
+	case ".jpg", ".jpeg":
+		return "image/jpeg"
+	case ".png":
+		return "image/png"
+	case ".gif":
+		return "image/gif"
+	case ".webp":
+		return "image/webp"
+	case ".avif":
+		return "image/avif"
+	case ".svg":
+		return "image/svg+xml"
+	}
+	return ""
+}
+
+// FeedID returns the ID of the feed published at selfURL,
+// or, when its URL isn't known, of the feed written to path.
+func FeedID(selfURL, path string) string {
+	if selfURL != "" {
+		return selfURL
+	}
+
+	p := strings.TrimPrefix(filepath.ToSlash(filepath.Clean(path)), "/")
+	return feedID + "/" + (&url.URL{Path: p}).EscapedPath()
+}
+
+// EntryID returns a tag URI (RFC 4151) identifying the bookmark,
+// e.g. "tag:instapaper.com,2025-02-10:bookmark/123".
+// Bookmarks published before tag URIs keep their numeric IDs.
+func EntryID(b structs.Bookmark) string {
+	if b.LegacyID {
+		return strconv.Itoa(b.ID)
+	}
+
+	return fmt.Sprintf(
+		"tag:instapaper.com,%s:bookmark/%d",
+		time.Unix(b.Time, 0).UTC().Format(time.DateOnly),
+		b.ID,
+	)
 }
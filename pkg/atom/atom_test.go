@@ -0,0 +1,203 @@
+package atom
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/chuhlomin/instapaper2rss/pkg/entry"
+	"github.com/chuhlomin/instapaper2rss/pkg/structs"
+	"github.com/chuhlomin/instapaper2rss/pkg/validate"
+)
+
+func TestFeedBuilder_Build(t *testing.T) {
+	b, err := FeedBuilder{
+		Title:    "Reading list",
+		Subtitle: "Saved articles",
+		SelfURL:  "https://example.com/atom.xml",
+		Author:   "Jane Doe",
+		Icon:     "https://example.com/icon.png",
+	}.Build([]structs.Bookmark{
+		{ID: 1, Time: 1739202544, Title: "Old", URL: "https://example.com/1", LegacyID: true},
+		{ID: 2, Time: 1739202544, Title: "New", URL: "https://example.com/2"},
+	})
+	require.NoError(t, err)
+
+	var feed Atom
+	require.NoError(t, xml.Unmarshal(b, &feed))
+
+	assert.Equal(t, "Reading list", feed.Title)
+	assert.Equal(t, "Saved articles", feed.Subtitle)
+	assert.Equal(t, "Jane Doe", feed.Author.Name)
+	assert.Equal(t, "https://example.com/icon.png", feed.Icon)
+	assert.Equal(t, "instapaper2rss", feed.Generator.Value)
+	assert.Equal(t, []Link{
+		{Rel: "self", Href: "https://example.com/atom.xml", Type: "application/atom+xml"},
+	}, feed.Link)
+
+	// newest first, then by ID
+	require.Len(t, feed.Entry, 2)
+	assert.Equal(t, "tag:instapaper.com,2025-02-10:bookmark/2", feed.Entry[0].ID)
+	assert.Equal(t, "1", feed.Entry[1].ID)
+	assert.Equal(t, "2025-02-10T15:49:04Z", feed.Updated)
+}
+
+func TestFeedBuilder_BuildDefaults(t *testing.T) {
+	b, err := FeedBuilder{}.Build(nil)
+	require.NoError(t, err)
+
+	var feed Atom
+	require.NoError(t, xml.Unmarshal(b, &feed))
+
+	assert.Equal(t, "Instapaper", feed.Title)
+	assert.Equal(t, "Instapaper", feed.Author.Name)
+	assert.Empty(t, feed.Link)
+	assert.NotContains(t, string(b), "<subtitle>")
+	assert.NotContains(t, string(b), "<icon>")
+}
+
+func TestFeedBuilder_BuildContent(t *testing.T) {
+	b, err := FeedBuilder{}.Build([]structs.Bookmark{
+		{ID: 1, Time: 1739202544, Text: "<p>Well-formed <em>article</em> text.</p>"},
+		{ID: 2, Time: 1739202544, Text: "<p>Tag soup<br>&nbsp;text", Description: "Description"},
+	})
+	require.NoError(t, err)
+
+	s := string(b)
+	assert.Contains(t, s, `<summary type="text">Well-formed article text.</summary>`)
+	assert.Contains(t, s, `<content type="xhtml">`)
+	assert.Contains(t, s, `<div xmlns="http://www.w3.org/1999/xhtml"><p>Well-formed <em>article</em> text.</p></div>`)
+	assert.Contains(t, s, `<summary type="text">Description</summary>`)
+	assert.Contains(t, s, `<content type="html">&lt;p&gt;Tag soup&lt;br&gt;&amp;nbsp;text</content>`)
+
+	var feed Atom
+	require.NoError(t, xml.Unmarshal(b, &feed))
+}
+
+func TestFeedBuilder_BuildAnalysis(t *testing.T) {
+	b, err := FeedBuilder{}.Build([]structs.Bookmark{
+		{ID: 1, Time: 1739202544, URL: "https://example.com/1", Text: "<p>Text</p>", Description: "Eine Beschreibung", Language: "de", ReadingTime: 5},
+		{ID: 2, Time: 1739202544, URL: "https://example.com/2", ReadingTime: 1},
+	})
+	require.NoError(t, err)
+	require.NoError(t, validate.Feed(b))
+
+	s := string(b)
+	assert.Contains(t, s, `<entry xml:lang="de">`)
+	assert.Contains(t, s, `<summary type="text">5 min read · Eine Beschreibung</summary>`)
+	assert.Contains(t, s, `<summary type="text">1 min read</summary>`)
+}
+
+func TestFeedBuilder_BuildMetadata(t *testing.T) {
+	b, err := FeedBuilder{}.Build([]structs.Bookmark{
+		{
+			ID:        1,
+			Time:      1739202544,
+			URL:       "https://example.com/1",
+			Title:     "With metadata",
+			Byline:    "Ada Lovelace",
+			SiteName:  "Example",
+			Published: 1730795400,
+			Image:     "https://example.com/lead.JPG?w=800",
+		},
+		{ID: 2, Time: 1739202544, URL: "https://example.com/2", Title: "Site only", SiteName: "Example"},
+		{ID: 3, Time: 1739202544, URL: "https://example.com/3", Title: "Without metadata"},
+	})
+	require.NoError(t, err)
+	require.NoError(t, validate.Feed(b))
+
+	var feed Atom
+	require.NoError(t, xml.Unmarshal(b, &feed))
+
+	s := string(b)
+	assert.Contains(t, s, `xmlns:media="http://search.yahoo.com/mrss/"`)
+	assert.Contains(t, s, `<published>`+time.Unix(1730795400, 0).Format(time.RFC3339)+`</published>`)
+	assert.Contains(t, s, `<author>
+      <name>Ada Lovelace</name>
+    </author>`)
+	assert.Contains(t, s, `<link rel="enclosure" href="https://example.com/lead.JPG?w=800" type="image/jpeg"></link>`)
+	assert.Contains(t, s, `<media:thumbnail url="https://example.com/lead.JPG?w=800"></media:thumbnail>`)
+	assert.Contains(t, s, `<name>Example</name>`)
+	assert.Equal(t, 1, strings.Count(s, "<published>"))
+	assert.Equal(t, 1, strings.Count(s, "<media:thumbnail"))
+
+	b, err = FeedBuilder{}.Build([]structs.Bookmark{{ID: 1, Time: 1739202544, URL: "https://example.com/1"}})
+	require.NoError(t, err)
+	assert.NotContains(t, string(b), "xmlns:media")
+}
+
+func TestFeedBuilder_BuildEntryTemplate(t *testing.T) {
+	tmpl, err := entry.Parse(`<p><a href="{{.URL}}">{{.Domain}}</a> · {{.ReadingTime}} min</p>{{.Text}}`)
+	require.NoError(t, err)
+
+	b, err := FeedBuilder{Entry: tmpl}.Build([]structs.Bookmark{
+		{ID: 1, Time: 1739202544, URL: "https://example.com/post", Text: "<p>Article text.</p>"},
+	})
+	require.NoError(t, err)
+	require.NoError(t, validate.Feed(b))
+	assert.Contains(t, string(b), `<div xmlns="http://www.w3.org/1999/xhtml"><p><a href="https://example.com/post">example.com</a> · 1 min</p><p>Article text.</p></div>`)
+
+	tmpl, err = entry.Parse(`{{.Missing}}`)
+	require.NoError(t, err)
+	_, err = FeedBuilder{Entry: tmpl}.Build([]structs.Bookmark{{ID: 1}})
+	assert.ErrorContains(t, err, "error rendering entry for bookmark 1")
+}
+
+func TestFeedBuilder_BuildDeterministic(t *testing.T) {
+	bookmarks := []structs.Bookmark{
+		{ID: 1, Time: 1739202544, Title: "First", URL: "https://example.com/1"},
+		{ID: 2, Time: 1739300000, Title: "Second", URL: "https://example.com/2"},
+		{ID: 3, Time: 1739202544, Title: "Third", URL: "https://example.com/3"},
+	}
+
+	first, err := FeedBuilder{}.Build(bookmarks)
+	require.NoError(t, err)
+
+	second, err := FeedBuilder{}.Build([]structs.Bookmark{bookmarks[2], bookmarks[1], bookmarks[0]})
+	require.NoError(t, err)
+	assert.Equal(t, string(first), string(second))
+
+	empty, err := FeedBuilder{}.Build(nil)
+	require.NoError(t, err)
+	require.NoError(t, validate.Feed(empty))
+}
+
+func TestFeedBuilder_BuildInvalidCharacters(t *testing.T) {
+	b, err := FeedBuilder{Title: "Reading\x00 list"}.Build([]structs.Bookmark{
+		{
+			ID:          1,
+			Time:        1739202544,
+			Title:       "Vertical\x0btab",
+			URL:         "https://example.com/\x1f",
+			Text:        "<p>caf\xe9\x08</p>",
+			Description: "Lone \xed\xa0\x80 surrogate",
+			Tags:        []string{"go\x00"},
+		},
+	})
+	require.NoError(t, err)
+	require.NoError(t, validate.Feed(b))
+
+	s := string(b)
+	assert.Contains(t, s, `<title>Reading list</title>`)
+	assert.Contains(t, s, `<title>Verticaltab</title>`)
+	assert.Contains(t, s, `<link href="https://example.com/"></link>`)
+	assert.Contains(t, s, `<p>caf`+"�"+`</p>`)
+	assert.Contains(t, s, `<category term="go"></category>`)
+}
+
+func TestFeedID(t *testing.T) {
+	assert.Equal(t, "https://example.com/atom.xml", FeedID("https://example.com/atom.xml", "public/atom.xml"))
+	assert.Equal(t, "https://github.com/chuhlomin/instapaper2rss/public/work%20feed.xml", FeedID("", "./public/work feed.xml"))
+	assert.Equal(t, "https://github.com/chuhlomin/instapaper2rss/tmp/atom.xml", FeedID("", "/tmp/atom.xml"))
+
+	b, err := FeedBuilder{ID: FeedID("", "work.xml")}.Build(nil)
+	require.NoError(t, err)
+
+	var feed Atom
+	require.NoError(t, xml.Unmarshal(b, &feed))
+	assert.Equal(t, "https://github.com/chuhlomin/instapaper2rss/work.xml", feed.ID)
+}
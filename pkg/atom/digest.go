@@ -0,0 +1,154 @@
+package atom
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/chuhlomin/instapaper2rss/pkg/structs"
+	"github.com/chuhlomin/instapaper2rss/pkg/xmltext"
+)
+
+// Digest periods.
+const (
+	Daily  = "day"
+	Weekly = "week"
+)
+
+// digestLink is the alternate link of digest entries.
+const digestLink = "https://www.instapaper.com/u"
+
+// DigestBuilder builds an Atom feed with one entry per UTC day or ISO week,
+// listing the bookmarks saved in it with links and excerpts.
+// Entry IDs only depend on the period, so a digest is updated in place
+// while bookmarks are added during the period.
+type DigestBuilder struct {
+	FeedBuilder
+	Period string // Daily or Weekly
+}
+
+type period struct {
+	key       string // "2006-01-02" or "2006-W01"
+	start     time.Time
+	bookmarks []structs.Bookmark
+}
+
+func (db DigestBuilder) Build(bookmarks []structs.Bookmark) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := db.BuildTo(&buf, bookmarks); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// BuildTo writes the feed to w as it is encoded.
+func (db DigestBuilder) BuildTo(w io.Writer, bookmarks []structs.Bookmark) error {
+	if db.Period != Daily && db.Period != Weekly {
+		return fmt.Errorf("unknown digest period %q, expected %q or %q", db.Period, Daily, Weekly)
+	}
+
+	sorted := sortNewestFirst(bookmarks)
+	periods := db.groupByPeriod(sorted)
+
+	feed, err := db.newFeed(nil)
+	if err != nil {
+		return err
+	}
+	feed.Updated = newestUpdated(sorted, feed.Updated)
+	feed.Entry = make([]Entry, len(periods))
+	for i, p := range periods {
+		feed.Entry[i] = db.newEntry(p)
+	}
+
+	if db.SelfURL != "" {
+		feed.Link = append(feed.Link, Link{
+			Rel:  "self",
+			Href: xmltext.Clean(db.SelfURL),
+			Type: "application/atom+xml",
+		})
+	}
+
+	return encode(w, feed)
+}
+
+// groupByPeriod groups bookmarks sorted newest first, newest period first.
+func (db DigestBuilder) groupByPeriod(sorted []structs.Bookmark) []period {
+	var periods []period
+	for _, b := range sorted {
+		t := time.Unix(b.Time, 0).UTC()
+		start := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+		key := start.Format(time.DateOnly)
+
+		if db.Period == Weekly {
+			// ISO weeks start on Monday
+			start = start.AddDate(0, 0, -(int(start.Weekday())+6)%7)
+			year, week := t.ISOWeek()
+			key = fmt.Sprintf("%d-W%02d", year, week)
+		}
+
+		if len(periods) == 0 || periods[len(periods)-1].key != key {
+			periods = append(periods, period{key: key, start: start})
+		}
+		periods[len(periods)-1].bookmarks = append(periods[len(periods)-1].bookmarks, b)
+	}
+	return periods
+}
+
+func (db DigestBuilder) newEntry(p period) Entry {
+	title := "Digest for " + p.start.Format("2 January 2006")
+	if db.Period == Weekly {
+		end := p.start.AddDate(0, 0, 6)
+		title = fmt.Sprintf("Digest for week of %s – %s", p.start.Format("2 January"), end.Format("2 January 2006"))
+	}
+
+	titles := make([]string, len(p.bookmarks))
+	for i, b := range p.bookmarks {
+		titles[i] = xmltext.Clean(b.FeedTitle())
+	}
+
+	summary := strconv.Itoa(len(p.bookmarks)) + " bookmarks: " + strings.Join(titles, ", ")
+	if len(p.bookmarks) == 1 {
+		summary = "1 bookmark: " + titles[0]
+	}
+
+	return Entry{
+		Title:   title,
+		Link:    []Link{{Href: digestLink}},
+		ID:      fmt.Sprintf("tag:instapaper.com,%s:digest/%s/%s", p.start.Format(time.DateOnly), db.Period, p.key),
+		Updated: newestUpdated(p.bookmarks, ""),
+		Summary: &Summary{Type: "text", Body: summary},
+		Content: Content{Type: "html", Body: digestHTML(p.bookmarks)},
+	}
+}
+
+// digestHTML lists the bookmarks with a table of contents
+// linking to a section per bookmark.
+func digestHTML(bookmarks []structs.Bookmark) string {
+	var sb strings.Builder
+
+	sb.WriteString("<ol>\n")
+	for _, b := range bookmarks {
+		fmt.Fprintf(&sb, "<li><a href=\"#bookmark-%d\">%s</a></li>\n", b.ID, html.EscapeString(b.FeedTitle()))
+	}
+	sb.WriteString("</ol>\n")
+
+	for _, b := range bookmarks {
+		fmt.Fprintf(&sb, "<h2 id=\"bookmark-%d\">", b.ID)
+		if b.URL != "" {
+			fmt.Fprintf(&sb, "<a href=\"%s\">%s</a>", html.EscapeString(b.URL), html.EscapeString(b.FeedTitle()))
+		} else {
+			sb.WriteString(html.EscapeString(b.FeedTitle()))
+		}
+		sb.WriteString("</h2>\n")
+
+		if summary := b.Summary(); summary != "" {
+			fmt.Fprintf(&sb, "<p>%s</p>\n", html.EscapeString(summary))
+		}
+	}
+
+	return xmltext.Clean(sb.String())
+}
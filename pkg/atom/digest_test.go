@@ -0,0 +1,85 @@
+package atom
+
+import (
+	"encoding/xml"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/chuhlomin/instapaper2rss/pkg/structs"
+	"github.com/chuhlomin/instapaper2rss/pkg/validate"
+)
+
+var digestBookmarks = []structs.Bookmark{
+	{ID: 1, Time: 1739202544, Title: "Monday", URL: "https://example.com/1", Description: "First <b>one</b>"},
+	{ID: 2, Time: 1739300000, Title: "Tuesday", URL: "https://example.com/2", Text: "<p>Second text</p>"},
+	{ID: 3, Time: 1739750000, Title: "Sunday", URL: "https://example.com/3"},
+	{ID: 4, Time: 1739800000, Title: "Next Monday", URL: "https://example.com/4", Starred: true},
+}
+
+func TestDigestBuilder_BuildDaily(t *testing.T) {
+	b, err := DigestBuilder{
+		FeedBuilder: FeedBuilder{SelfURL: "https://example.com/digest.xml"},
+		Period:      Daily,
+	}.Build(digestBookmarks)
+	require.NoError(t, err)
+	require.NoError(t, validate.Feed(b))
+
+	var feed Atom
+	require.NoError(t, xml.Unmarshal(b, &feed))
+
+	assert.Equal(t, "2025-02-17T13:46:40Z", feed.Updated)
+	require.Len(t, feed.Entry, 4)
+
+	assert.Equal(t, "Digest for 17 February 2025", feed.Entry[0].Title)
+	assert.Equal(t, "tag:instapaper.com,2025-02-17:digest/day/2025-02-17", feed.Entry[0].ID)
+	assert.Equal(t, "1 bookmark: ★ Next Monday", feed.Entry[0].Summary.Body)
+
+	assert.Equal(t, "tag:instapaper.com,2025-02-10:digest/day/2025-02-10", feed.Entry[3].ID)
+	assert.Equal(t, "2025-02-10T15:49:04Z", feed.Entry[3].Updated)
+	assert.Equal(t, "html", feed.Entry[3].Content.Type)
+	assert.Equal(t, `<ol>
+<li><a href="#bookmark-1">Monday</a></li>
+</ol>
+<h2 id="bookmark-1"><a href="https://example.com/1">Monday</a></h2>
+<p>First &lt;b&gt;one&lt;/b&gt;</p>
+`, feed.Entry[3].Content.Body)
+}
+
+func TestDigestBuilder_BuildWeekly(t *testing.T) {
+	b, err := DigestBuilder{Period: Weekly}.Build(digestBookmarks)
+	require.NoError(t, err)
+	require.NoError(t, validate.Feed(b))
+
+	var feed Atom
+	require.NoError(t, xml.Unmarshal(b, &feed))
+
+	require.Len(t, feed.Entry, 2)
+	assert.Equal(t, "tag:instapaper.com,2025-02-17:digest/week/2025-W08", feed.Entry[0].ID)
+
+	week := feed.Entry[1]
+	assert.Equal(t, "Digest for week of 10 February – 16 February 2025", week.Title)
+	assert.Equal(t, "tag:instapaper.com,2025-02-10:digest/week/2025-W07", week.ID)
+	assert.Equal(t, "2025-02-16T23:53:20Z", week.Updated)
+	assert.Equal(t, "3 bookmarks: Sunday, Tuesday, Monday", week.Summary.Body)
+	assert.Contains(t, week.Content.Body, `<h2 id="bookmark-2"><a href="https://example.com/2">Tuesday</a></h2>
+<p>Second text</p>`)
+
+	// adding a bookmark to the week updates the same entry
+	b, err = DigestBuilder{Period: Weekly}.Build(append(digestBookmarks,
+		structs.Bookmark{ID: 5, Time: 1739400000, Title: "Wednesday"},
+	))
+	require.NoError(t, err)
+
+	var updated Atom
+	require.NoError(t, xml.Unmarshal(b, &updated))
+	require.Len(t, updated.Entry, 2)
+	assert.Equal(t, week.ID, updated.Entry[1].ID)
+	assert.Contains(t, updated.Entry[1].Summary.Body, "4 bookmarks")
+}
+
+func TestDigestBuilder_BuildUnknownPeriod(t *testing.T) {
+	_, err := DigestBuilder{Period: "month"}.Build(nil)
+	assert.EqualError(t, err, `unknown digest period "month", expected "day" or "week"`)
+}
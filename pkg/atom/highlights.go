@@ -0,0 +1,100 @@
+package atom
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/chuhlomin/instapaper2rss/pkg/entry"
+	"github.com/chuhlomin/instapaper2rss/pkg/structs"
+	"github.com/chuhlomin/instapaper2rss/pkg/xmltext"
+)
+
+// HighlightsBuilder builds an Atom feed with one entry per highlight,
+// newest first, quoting the passage and linking to the article.
+type HighlightsBuilder struct {
+	FeedBuilder
+}
+
+type highlight struct {
+	structs.Highlight
+	bookmark structs.Bookmark
+}
+
+func (hb HighlightsBuilder) Build(bookmarks []structs.Bookmark) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := hb.BuildTo(&buf, bookmarks); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// BuildTo writes the feed to w as it is encoded.
+func (hb HighlightsBuilder) BuildTo(w io.Writer, bookmarks []structs.Bookmark) error {
+	var highlights []highlight
+	for _, b := range bookmarks {
+		b = xmltext.Bookmark(b)
+		for _, h := range b.Highlights {
+			highlights = append(highlights, highlight{Highlight: h, bookmark: b})
+		}
+	}
+
+	sort.SliceStable(highlights, func(i, j int) bool {
+		if highlights[i].Time != highlights[j].Time {
+			return highlights[i].Time > highlights[j].Time
+		}
+		return highlights[i].ID > highlights[j].ID
+	})
+
+	feed, err := hb.newFeed(nil)
+	if err != nil {
+		return err
+	}
+	feed.Entry = make([]Entry, len(highlights))
+	for i, h := range highlights {
+		feed.Entry[i] = newHighlightEntry(h)
+	}
+	if len(highlights) > 0 {
+		feed.Updated = feed.Entry[0].Updated
+	}
+
+	if hb.SelfURL != "" {
+		feed.Link = append(feed.Link, Link{
+			Rel:  "self",
+			Href: xmltext.Clean(hb.SelfURL),
+			Type: "application/atom+xml",
+		})
+	}
+
+	return encode(w, feed)
+}
+
+func newHighlightEntry(h highlight) Entry {
+	t := time.Unix(h.Time, 0)
+	text := xmltext.Clean(h.Text)
+	title := h.bookmark.FeedTitle()
+
+	source := html.EscapeString(title)
+	if h.bookmark.URL != "" {
+		source = fmt.Sprintf("<a href=\"%s\">%s</a>", html.EscapeString(h.bookmark.URL), source)
+	}
+
+	e := Entry{
+		Lang:    h.bookmark.Language,
+		Title:   title,
+		Link:    []Link{{Href: h.bookmark.URL}},
+		ID:      fmt.Sprintf("tag:instapaper.com,%s:highlight/%d", t.UTC().Format(time.DateOnly), h.ID),
+		Updated: t.Format(time.RFC3339),
+		Summary: &Summary{Type: "text", Body: text},
+		Content: newContent(entry.Quote(text) + "<p>From " + source + "</p>"),
+	}
+
+	for _, tag := range h.bookmark.Tags {
+		e.Category = append(e.Category, Category{Term: tag})
+	}
+
+	return e
+}
@@ -0,0 +1,68 @@
+package atom
+
+import (
+	"encoding/xml"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/chuhlomin/instapaper2rss/pkg/structs"
+	"github.com/chuhlomin/instapaper2rss/pkg/validate"
+)
+
+func TestHighlightsBuilder_Build(t *testing.T) {
+	b, err := HighlightsBuilder{
+		FeedBuilder: FeedBuilder{Title: "Highlights", SelfURL: "https://example.com/highlights.xml"},
+	}.Build([]structs.Bookmark{
+		{
+			ID:    1,
+			Time:  1739202544,
+			Title: "Article & more",
+			URL:   "https://example.com/1",
+			Tags:  []string{"go"},
+			Highlights: []structs.Highlight{
+				{ID: 10, Text: "First passage", Time: 1739300000},
+				{ID: 11, Text: "Second <passage>\x00", Time: 1739400000, Position: 1},
+			},
+		},
+		{ID: 2, Time: 1739202544, Title: "Without highlights", URL: "https://example.com/2"},
+		{
+			ID:         3,
+			Time:       1739202544,
+			Title:      "Other",
+			URL:        "https://example.com/3",
+			Highlights: []structs.Highlight{{ID: 12, Text: "Other passage", Time: 1739350000}},
+		},
+	})
+	require.NoError(t, err)
+	require.NoError(t, validate.Feed(b))
+
+	var feed Atom
+	require.NoError(t, xml.Unmarshal(b, &feed))
+
+	assert.Equal(t, "2025-02-12T22:40:00Z", feed.Updated)
+	require.Len(t, feed.Entry, 3)
+
+	e := feed.Entry[0]
+	assert.Equal(t, "tag:instapaper.com,2025-02-12:highlight/11", e.ID)
+	assert.Equal(t, "Article & more", e.Title)
+	assert.Equal(t, "https://example.com/1", e.Link[0].Href)
+	assert.Equal(t, "Second <passage>", e.Summary.Body)
+	assert.Equal(t, []Category{{Term: "go"}}, e.Category)
+	assert.Equal(t, "xhtml", e.Content.Type)
+	assert.Equal(t,
+		`<blockquote><p>Second &lt;passage&gt;</p></blockquote><p>From <a href="https://example.com/1">Article &amp; more</a></p>`,
+		e.Content.XHTML.Body,
+	)
+
+	assert.Equal(t, "tag:instapaper.com,2025-02-12:highlight/12", feed.Entry[1].ID)
+	assert.Equal(t, "tag:instapaper.com,2025-02-11:highlight/10", feed.Entry[2].ID)
+}
+
+func TestHighlightsBuilder_BuildEmpty(t *testing.T) {
+	b, err := HighlightsBuilder{}.Build([]structs.Bookmark{{ID: 1, Time: 1739202544}})
+	require.NoError(t, err)
+	require.NoError(t, validate.Feed(b))
+	assert.NotContains(t, string(b), "<entry>")
+}
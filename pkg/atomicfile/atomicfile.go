@@ -0,0 +1,177 @@
+// Package atomicfile writes files so that readers, and the next run
+// after a crash, see either the previous or the new content of a file,
+// never a partially written one.
+package atomicfile
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// File is a temporary file in the directory of Path,
+// renamed over Path on Commit.
+type File struct {
+	path string
+	tmp  *os.File
+	hash hash.Hash
+	size int64
+	done bool
+}
+
+// Create creates a temporary file for path, creating its directory
+// if needed. The file must be committed or aborted.
+func Create(path string) (*File, error) {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("error creating directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return nil, fmt.Errorf("error creating file: %w", err)
+	}
+
+	return &File{path: path, tmp: tmp, hash: sha256.New()}, nil
+}
+
+// WriteFile writes data to path atomically.
+func WriteFile(path string, data []byte) error {
+	f, err := Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Abort()
+
+	if _, err := f.Write(data); err != nil {
+		return err
+	}
+
+	return f.Commit()
+}
+
+func (f *File) Write(p []byte) (int, error) {
+	n, err := f.tmp.Write(p)
+	f.hash.Write(p[:n])
+	f.size += int64(n)
+	if err != nil {
+		return n, fmt.Errorf("error writing file: %w", err)
+	}
+	return n, nil
+}
+
+// Path returns the path the file is committed to.
+func (f *File) Path() string {
+	return f.path
+}
+
+// Size returns the number of bytes written.
+func (f *File) Size() int64 {
+	return f.size
+}
+
+// Sum returns the hex-encoded SHA-256 of the content written so far.
+func (f *File) Sum() string {
+	return hex.EncodeToString(f.hash.Sum(nil))
+}
+
+// Open opens the content written so far for reading.
+func (f *File) Open() (*os.File, error) {
+	return os.Open(f.tmp.Name())
+}
+
+// Unchanged reports whether the file at Path already has the content.
+func (f *File) Unchanged() bool {
+	existing, err := os.Open(f.path)
+	if err != nil {
+		return false
+	}
+	defer existing.Close()
+
+	if fi, err := existing.Stat(); err != nil || fi.Size() != f.size {
+		return false
+	}
+
+	h := sha256.New()
+	if _, err := io.Copy(h, existing); err != nil {
+		return false
+	}
+
+	return bytes.Equal(h.Sum(nil), f.hash.Sum(nil))
+}
+
+// Commit flushes the content to disk and renames the file over Path.
+func (f *File) Commit() error {
+	if f.done {
+		return fmt.Errorf("file %s is already closed", f.path)
+	}
+	f.done = true
+
+	name := f.tmp.Name()
+	if err := commit(f.tmp, f.path); err != nil {
+		os.Remove(name)
+		return err
+	}
+
+	// make the rename itself durable
+	return syncDir(filepath.Dir(f.path))
+}
+
+func commit(tmp *os.File, path string) error {
+	defer tmp.Close()
+
+	// temporary files are only readable by the owner
+	if err := tmp.Chmod(0o644); err != nil {
+		return fmt.Errorf("error setting file mode: %w", err)
+	}
+
+	if err := tmp.Sync(); err != nil {
+		return fmt.Errorf("error syncing file: %w", err)
+	}
+
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("error closing file: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("error renaming file: %w", err)
+	}
+
+	return nil
+}
+
+// Abort removes the temporary file. It does nothing after Commit,
+// so it can be deferred.
+func (f *File) Abort() error {
+	if f.done {
+		return nil
+	}
+	f.done = true
+
+	f.tmp.Close()
+	return os.Remove(f.tmp.Name())
+}
+
+func syncDir(dir string) error {
+	// directories can't be opened for syncing on Windows
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+
+	d, err := os.Open(dir)
+	if err != nil {
+		return fmt.Errorf("error syncing directory: %w", err)
+	}
+	defer d.Close()
+
+	if err := d.Sync(); err != nil {
+		return fmt.Errorf("error syncing directory: %w", err)
+	}
+	return nil
+}
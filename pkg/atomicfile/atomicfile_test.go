@@ -0,0 +1,64 @@
+package atomicfile
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFile_Commit(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "feeds", "atom.xml")
+
+	require.NoError(t, WriteFile(path, []byte("old")))
+
+	f, err := Create(path)
+	require.NoError(t, err)
+	_, err = f.Write([]byte("new feed"))
+	require.NoError(t, err)
+
+	// readers see the previous content until the file is committed
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "old", string(data))
+	assert.False(t, f.Unchanged())
+	assert.Equal(t, int64(8), f.Size())
+
+	require.NoError(t, f.Commit())
+	require.NoError(t, f.Abort(), "abort after commit does nothing")
+
+	data, err = os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "new feed", string(data))
+
+	fi, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o644), fi.Mode().Perm())
+
+	entries, err := os.ReadDir(filepath.Dir(path))
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
+
+func TestFile_Abort(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "atom.xml")
+	require.NoError(t, WriteFile(path, []byte("feed")))
+
+	f, err := Create(path)
+	require.NoError(t, err)
+	_, err = f.Write([]byte("feed"))
+	require.NoError(t, err)
+
+	assert.True(t, f.Unchanged())
+	assert.Equal(t, "c8bc2586cdd87cd6f970fc4262c4bbc49165811788825fbc1ba3366bfde45413", f.Sum())
+
+	require.NoError(t, f.Abort())
+	assert.Error(t, f.Commit())
+
+	entries, err := os.ReadDir(filepath.Dir(path))
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
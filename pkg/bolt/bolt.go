@@ -1,6 +1,7 @@
 package bolt
 
 import (
+	"encoding/binary"
 	"encoding/json"
 	"fmt"
 	"strconv"
@@ -15,7 +16,16 @@ type Storage struct {
 	db *b.DB
 }
 
-const bucketName = "bookmarks"
+const (
+	bucketName     = "bookmarks"
+	runsBucketName = "runs"
+)
+
+type Stats struct {
+	Bookmarks int
+	Runs      int
+	Size      int64
+}
 
 func NewStorage(path string) (*Storage, error) {
 	db, err := b.Open(
@@ -27,12 +37,18 @@ func NewStorage(path string) (*Storage, error) {
 		return nil, err
 	}
 
-	db.Update(func(tx *b.Tx) error {
-		if _, err := tx.CreateBucketIfNotExists([]byte(bucketName)); err != nil {
-			return fmt.Errorf("create bucket: %s", err)
+	err = db.Update(func(tx *b.Tx) error {
+		for _, name := range []string{bucketName, runsBucketName, metaBucketName} {
+			if _, err := tx.CreateBucketIfNotExists([]byte(name)); err != nil {
+				return fmt.Errorf("create bucket %q: %s", name, err)
+			}
 		}
-		return nil
+		return migrate(tx)
 	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
 
 	return &Storage{db: db}, nil
 }
@@ -82,6 +98,145 @@ func (s *Storage) WriteBookmark(bookmark *structs.Bookmark) error {
 	return err
 }
 
+// WriteRun appends a run record, assigning it the next run ID.
+func (s *Storage) WriteRun(run *structs.Run) error {
+	return s.db.Update(func(tx *b.Tx) error {
+		b := tx.Bucket([]byte(runsBucketName))
+		if b == nil {
+			return fmt.Errorf("bucket %q not found", runsBucketName)
+		}
+
+		id, err := b.NextSequence()
+		if err != nil {
+			return err
+		}
+		run.ID = int(id)
+
+		val, err := json.Marshal(run)
+		if err != nil {
+			return err
+		}
+
+		return b.Put(itob(id), val)
+	})
+}
+
+// GetRuns returns up to limit most recent runs, newest first.
+// A limit of zero or less returns all runs.
+func (s *Storage) GetRuns(limit int) ([]structs.Run, error) {
+	var runs []structs.Run
+
+	err := s.db.View(func(tx *b.Tx) error {
+		b := tx.Bucket([]byte(runsBucketName))
+		if b == nil {
+			return fmt.Errorf("bucket %q not found", runsBucketName)
+		}
+
+		c := b.Cursor()
+		for k, v := c.Last(); k != nil; k, v = c.Prev() {
+			if limit > 0 && len(runs) >= limit {
+				break
+			}
+
+			var run structs.Run
+			if err := json.Unmarshal(v, &run); err != nil {
+				return err
+			}
+
+			runs = append(runs, run)
+		}
+		return nil
+	})
+
+	return runs, err
+}
+
+// LastSuccessfulRun returns the most recent sync without failures,
+// or nil if there is none.
+func (s *Storage) LastSuccessfulRun() (*structs.Run, error) {
+	var last *structs.Run
+
+	err := s.db.View(func(tx *b.Tx) error {
+		b := tx.Bucket([]byte(runsBucketName))
+		if b == nil {
+			return fmt.Errorf("bucket %q not found", runsBucketName)
+		}
+
+		c := b.Cursor()
+		for k, v := c.Last(); k != nil; k, v = c.Prev() {
+			var run structs.Run
+			if err := json.Unmarshal(v, &run); err != nil {
+				return err
+			}
+
+			// builds don't sync
+			if run.OK() && run.Command != "build" {
+				last = &run
+				return nil
+			}
+		}
+		return nil
+	})
+
+	return last, err
+}
+
+func (s *Storage) Stats() (Stats, error) {
+	var stats Stats
+
+	err := s.db.View(func(tx *b.Tx) error {
+		stats.Size = tx.Size()
+
+		if b := tx.Bucket([]byte(bucketName)); b != nil {
+			stats.Bookmarks = b.Stats().KeyN
+		}
+
+		if b := tx.Bucket([]byte(runsBucketName)); b != nil {
+			stats.Runs = b.Stats().KeyN
+		}
+
+		return nil
+	})
+
+	return stats, err
+}
+
+// GetMeta returns the value stored under key in the meta bucket,
+// or an empty string if there is none.
+func (s *Storage) GetMeta(key string) (string, error) {
+	var value string
+
+	err := s.db.View(func(tx *b.Tx) error {
+		b := tx.Bucket([]byte(metaBucketName))
+		if b == nil {
+			return fmt.Errorf("bucket %q not found", metaBucketName)
+		}
+
+		value = string(b.Get([]byte(key)))
+		return nil
+	})
+
+	return value, err
+}
+
+// SetMeta stores value under key in the meta bucket.
+func (s *Storage) SetMeta(key, value string) error {
+	return s.db.Update(func(tx *b.Tx) error {
+		b := tx.Bucket([]byte(metaBucketName))
+		if b == nil {
+			return fmt.Errorf("bucket %q not found", metaBucketName)
+		}
+
+		return b.Put([]byte(key), []byte(value))
+	})
+}
+
 func (s *Storage) Close() error {
 	return s.db.Close()
 }
+
+func itob(v uint64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, v)
+	return b
+}
@@ -0,0 +1,120 @@
+package bolt
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	b "github.com/boltdb/bolt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/chuhlomin/instapaper2rss/pkg/structs"
+)
+
+func TestNewStorage_Migrate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "instapaper.db")
+
+	// database written before schema versions existed
+	db, err := b.Open(path, 0600, nil)
+	require.NoError(t, err)
+	require.NoError(t, db.Update(func(tx *b.Tx) error {
+		bucket, err := tx.CreateBucket([]byte(bucketName))
+		if err != nil {
+			return err
+		}
+		return bucket.Put(
+			[]byte("1"),
+			[]byte(`{"ID":1,"Time":1739202544,"Title":"Test","URL":"https://example.com","Hash":"abc123","Text":"<p onclick=\"x()\">Test content</p><script>alert(1)</script>"}`),
+		)
+	}))
+	require.NoError(t, db.Close())
+
+	storage, err := NewStorage(path)
+	require.NoError(t, err)
+
+	bookmarks, err := storage.GetBookmarks()
+	require.NoError(t, err)
+	assert.Equal(t, []structs.Bookmark{
+		{
+			ID:          1,
+			Time:        1739202544,
+			Title:       "Test",
+			URL:         "https://example.com",
+			Text:        "<p>Test content</p>",
+			Folder:      "unread",
+			LegacyID:    true,
+			Words:       2,
+			ReadingTime: 1,
+		},
+	}, bookmarks)
+
+	// migrations run once
+	bookmarks[0].Hash = "abc456"
+	require.NoError(t, storage.WriteBookmark(&bookmarks[0]))
+	require.NoError(t, storage.Close())
+
+	storage, err = NewStorage(path)
+	require.NoError(t, err)
+	defer storage.Close()
+
+	bookmarks, err = storage.GetBookmarks()
+	require.NoError(t, err)
+	assert.Equal(t, "abc456", bookmarks[0].Hash)
+}
+
+func TestStorage_Runs(t *testing.T) {
+	storage, err := NewStorage(filepath.Join(t.TempDir(), "instapaper.db"))
+	require.NoError(t, err)
+	defer storage.Close()
+
+	last, err := storage.LastSuccessfulRun()
+	require.NoError(t, err)
+	assert.Nil(t, last)
+
+	start := time.Date(2025, 3, 1, 13, 0, 0, 0, time.UTC)
+	for i, run := range []structs.Run{
+		{Start: start, End: start.Add(time.Second), NewBookmarks: 2},
+		{Start: start.Add(time.Hour), End: start.Add(time.Hour), NewBookmarks: 1},
+		{Start: start.Add(2 * time.Hour), End: start.Add(2 * time.Hour), Failures: 1, Error: "boom"},
+		{Command: "build", Start: start.Add(3 * time.Hour), End: start.Add(3 * time.Hour), FeedSize: 10},
+	} {
+		require.NoError(t, storage.WriteRun(&run))
+		assert.Equal(t, i+1, run.ID)
+	}
+
+	runs, err := storage.GetRuns(2)
+	require.NoError(t, err)
+	if assert.Len(t, runs, 2) {
+		assert.Equal(t, 4, runs[0].ID)
+		assert.Equal(t, "build", runs[0].Command)
+		assert.Equal(t, 3, runs[1].ID)
+	}
+
+	last, err = storage.LastSuccessfulRun()
+	require.NoError(t, err)
+	if assert.NotNil(t, last) {
+		assert.Equal(t, 2, last.ID)
+	}
+
+	stats, err := storage.Stats()
+	require.NoError(t, err)
+	assert.Equal(t, 4, stats.Runs)
+	assert.Equal(t, 0, stats.Bookmarks)
+}
+
+func TestStorage_Meta(t *testing.T) {
+	storage, err := NewStorage(filepath.Join(t.TempDir(), "instapaper.db"))
+	require.NoError(t, err)
+	defer storage.Close()
+
+	value, err := storage.GetMeta("epub_last_export")
+	require.NoError(t, err)
+	assert.Equal(t, "", value)
+
+	require.NoError(t, storage.SetMeta("epub_last_export", "1740000000"))
+
+	value, err = storage.GetMeta("epub_last_export")
+	require.NoError(t, err)
+	assert.Equal(t, "1740000000", value)
+}
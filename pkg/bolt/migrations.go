@@ -0,0 +1,163 @@
+package bolt
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+
+	b "github.com/boltdb/bolt"
+
+	"github.com/chuhlomin/instapaper2rss/pkg/analyze"
+	"github.com/chuhlomin/instapaper2rss/pkg/extract"
+	"github.com/chuhlomin/instapaper2rss/pkg/sanitize"
+	"github.com/chuhlomin/instapaper2rss/pkg/structs"
+)
+
+const (
+	metaBucketName   = "meta"
+	schemaVersionKey = "schema_version"
+)
+
+// migrations upgrade stored data from one schema version to the next:
+// migrations[i] upgrades version i to i+1.
+var migrations = []func(tx *b.Tx) error{
+	migrateBookmarkDetails,
+	migrateLegacyIDs,
+	migrateHighlights,
+	migrateAnalysis,
+	migrateMetadata,
+	migrateSanitize,
+}
+
+func migrate(tx *b.Tx) error {
+	meta := tx.Bucket([]byte(metaBucketName))
+	if meta == nil {
+		return fmt.Errorf("bucket %q not found", metaBucketName)
+	}
+
+	version := 0
+	if v := meta.Get([]byte(schemaVersionKey)); v != nil {
+		var err error
+		if version, err = strconv.Atoi(string(v)); err != nil {
+			return fmt.Errorf("invalid schema version %q: %w", v, err)
+		}
+	}
+
+	if version > len(migrations) {
+		return fmt.Errorf("schema version %d is newer than supported %d", version, len(migrations))
+	}
+
+	for ; version < len(migrations); version++ {
+		if err := migrations[version](tx); err != nil {
+			return fmt.Errorf("migration to schema version %d: %w", version+1, err)
+		}
+	}
+
+	return meta.Put([]byte(schemaVersionKey), []byte(strconv.Itoa(version)))
+}
+
+// migrateBookmarkDetails prepares bookmarks saved before description,
+// tags, folder, starred and progress were stored.
+// All of them were synced from the "unread" folder.
+// Clearing the hash drops them from the "have" list, so the next sync
+// lists them again and fills in the new fields without refetching text.
+func migrateBookmarkDetails(tx *b.Tx) error {
+	return updateBookmarks(tx, func(bookmark *structs.Bookmark) {
+		if bookmark.Folder == "" {
+			bookmark.Folder = "unread"
+		}
+		bookmark.Hash = ""
+	})
+}
+
+// migrateLegacyIDs marks bookmarks that were already published
+// with numeric Atom entry IDs, so feeds keep using them
+// and readers don't show those entries again.
+func migrateLegacyIDs(tx *b.Tx) error {
+	return updateBookmarks(tx, func(bookmark *structs.Bookmark) {
+		bookmark.LegacyID = true
+	})
+}
+
+// migrateHighlights clears hashes so the next sync lists
+// all bookmarks again, along with their highlights.
+func migrateHighlights(tx *b.Tx) error {
+	return updateBookmarks(tx, func(bookmark *structs.Bookmark) {
+		bookmark.Hash = ""
+	})
+}
+
+// migrateAnalysis fills in the word count, reading time
+// and language of stored bookmarks.
+func migrateAnalysis(tx *b.Tx) error {
+	return updateBookmarks(tx, analyze.Bookmark)
+}
+
+// migrateMetadata extracts metadata from the text of stored bookmarks.
+// Text stored since sanitization was added has lost its meta tags and
+// bylines, so usually only the lead image is found; older text is
+// sanitized by the next migration, after its metadata is extracted.
+func migrateMetadata(tx *b.Tx) error {
+	return updateBookmarks(tx, func(bookmark *structs.Bookmark) {
+		// bookmarks with unparsable URLs keep their fields empty
+		_ = extract.Bookmark(bookmark)
+	})
+}
+
+// migrateSanitize sanitizes the text of stored bookmarks with the default
+// "reader-friendly" policy, as bookmarks stored before sanitization
+// was added would publish their scripts, iframes and event handlers.
+// Text sanitized before is left as it is, except for elements allowed
+// with SANITIZE_ALLOW, as storage doesn't know the configured policy.
+func migrateSanitize(tx *b.Tx) error {
+	policy := sanitize.ReaderFriendly()
+
+	var errs []error
+	err := updateBookmarks(tx, func(bookmark *structs.Bookmark) {
+		text, _, err := policy.Sanitize(bookmark.Text)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("bookmark %d: %w", bookmark.ID, err))
+			return
+		}
+
+		bookmark.Text = text
+		analyze.Bookmark(bookmark)
+	})
+	return errors.Join(append(errs, err)...)
+}
+
+func updateBookmarks(tx *b.Tx, update func(*structs.Bookmark)) error {
+	bucket := tx.Bucket([]byte(bucketName))
+	if bucket == nil {
+		return fmt.Errorf("bucket %q not found", bucketName)
+	}
+
+	updated := map[string][]byte{}
+	err := bucket.ForEach(func(k, v []byte) error {
+		var bookmark structs.Bookmark
+		if err := json.Unmarshal(v, &bookmark); err != nil {
+			return fmt.Errorf("bookmark %s: %w", k, err)
+		}
+
+		update(&bookmark)
+
+		val, err := json.Marshal(bookmark)
+		if err != nil {
+			return fmt.Errorf("bookmark %s: %w", k, err)
+		}
+		updated[string(k)] = val
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// bolt does not allow modifying a bucket while iterating over it
+	for k, v := range updated {
+		if err := bucket.Put([]byte(k), v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
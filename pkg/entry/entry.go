@@ -0,0 +1,125 @@
+package entry
+
+import (
+	"fmt"
+	"html"
+	"html/template"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/chuhlomin/instapaper2rss/pkg/analyze"
+	"github.com/chuhlomin/instapaper2rss/pkg/structs"
+)
+
+// Template renders the content of feed entries from bookmarks.
+// A nil Template renders the highlights followed by the article text.
+type Template struct {
+	tmpl *template.Template
+}
+
+// Data is passed to entry templates.
+// Text and HighlightsHTML are inserted without escaping.
+type Data struct {
+	structs.Bookmark
+
+	Text           template.HTML
+	HighlightsHTML template.HTML // the highlights section, empty without highlights
+	Domain         string        // host of the bookmark URL, without "www."
+	InstapaperURL  string        // the bookmark in the Instapaper reader
+}
+
+// Parse parses an html/template for entry content.
+func Parse(text string) (*Template, error) {
+	tmpl, err := template.New("entry").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing entry template: %w", err)
+	}
+
+	return &Template{tmpl: tmpl}, nil
+}
+
+// ParseFile parses the entry template at path.
+func ParseFile(path string) (*Template, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading entry template: %w", err)
+	}
+
+	t, err := Parse(string(b))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filepath.Base(path), err)
+	}
+	return t, nil
+}
+
+// Render returns the entry content for the bookmark.
+func (t *Template) Render(b structs.Bookmark) (string, error) {
+	if t == nil {
+		return Highlights(b) + b.Text, nil
+	}
+
+	var sb strings.Builder
+	if err := t.tmpl.Execute(&sb, NewData(b)); err != nil {
+		return "", fmt.Errorf("error rendering entry for bookmark %d: %w", b.ID, err)
+	}
+	return sb.String(), nil
+}
+
+// NewData returns the template data for the bookmark.
+// Word counts and reading times are estimated for bookmarks
+// stored without them.
+func NewData(b structs.Bookmark) Data {
+	if b.Words == 0 && b.Text != "" {
+		result := analyze.Text(b.Text)
+		b.Words, b.ReadingTime = result.Words, result.ReadingTime
+	}
+
+	return Data{
+		Bookmark:       b,
+		Text:           template.HTML(b.Text),
+		HighlightsHTML: template.HTML(Highlights(b)),
+		Domain:         domain(b.URL),
+		InstapaperURL:  "https://www.instapaper.com/read/" + strconv.Itoa(b.ID),
+	}
+}
+
+// Highlights returns the highlights of the bookmark as a section
+// of quotes to put above the article, or an empty string.
+func Highlights(b structs.Bookmark) string {
+	if len(b.Highlights) == 0 {
+		return ""
+	}
+
+	var sb strings.Builder
+	sb.WriteString("<section><h2>Highlights</h2>")
+	for _, h := range b.Highlights {
+		sb.WriteString(Quote(h.Text))
+	}
+	sb.WriteString("</section><hr/>")
+	return sb.String()
+}
+
+// Quote returns the highlighted passage as a blockquote,
+// with a paragraph per line.
+func Quote(text string) string {
+	var sb strings.Builder
+	sb.WriteString("<blockquote>")
+	for _, line := range strings.Split(text, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			sb.WriteString("<p>" + html.EscapeString(line) + "</p>")
+		}
+	}
+	sb.WriteString("</blockquote>")
+	return sb.String()
+}
+
+func domain(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimPrefix(u.Hostname(), "www.")
+}
@@ -0,0 +1,93 @@
+package entry
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/chuhlomin/instapaper2rss/pkg/structs"
+)
+
+var bookmark = structs.Bookmark{
+	ID:          123,
+	Title:       "Title",
+	URL:         "https://www.example.com/post?a=1&b=2",
+	Description: "Tom & Jerry",
+	Tags:        []string{"go", "<b>"},
+	Text:        "<p>" + strings.Repeat("word ", 500) + "</p>",
+}
+
+func TestTemplate_Render(t *testing.T) {
+	tmpl, err := ParseFile("testdata/header.html")
+	require.NoError(t, err)
+
+	got, err := tmpl.Render(bookmark)
+	require.NoError(t, err)
+	assert.Equal(t, `<p><a href="https://www.example.com/post?a=1&amp;b=2">example.com</a> · 3 min read · go · &lt;b&gt;</p>
+<p><em>Tom &amp; Jerry</em></p>
+`+bookmark.Text+`
+<p><a href="https://www.instapaper.com/read/123">Open in Instapaper</a></p>
+`, got)
+}
+
+func TestTemplate_RenderNil(t *testing.T) {
+	var tmpl *Template
+
+	got, err := tmpl.Render(bookmark)
+	require.NoError(t, err)
+	assert.Equal(t, bookmark.Text, got)
+}
+
+func TestTemplate_Errors(t *testing.T) {
+	_, err := Parse("{{.Text")
+	assert.ErrorContains(t, err, "error parsing entry template")
+
+	_, err = ParseFile("testdata/missing.html")
+	assert.ErrorContains(t, err, "error reading entry template")
+
+	tmpl, err := Parse("{{.Missing}}")
+	require.NoError(t, err)
+	_, err = tmpl.Render(bookmark)
+	assert.ErrorContains(t, err, "error rendering entry for bookmark 123")
+}
+
+func TestNewData(t *testing.T) {
+	data := NewData(structs.Bookmark{URL: "https://blog.example.com:8080/", Text: "<p>one two</p>"})
+	assert.Equal(t, "blog.example.com", data.Domain)
+	assert.Equal(t, 2, data.Words)
+	assert.Equal(t, 1, data.ReadingTime)
+
+	data = NewData(structs.Bookmark{})
+	assert.Equal(t, 0, data.ReadingTime)
+	assert.Equal(t, "", data.Domain)
+}
+
+func TestHighlights(t *testing.T) {
+	b := structs.Bookmark{
+		Text: "<p>Article</p>",
+		Highlights: []structs.Highlight{
+			{ID: 1, Text: "A <passage>"},
+			{ID: 2, Text: "First line\n\nsecond line"},
+		},
+	}
+
+	expected := "<section><h2>Highlights</h2>" +
+		"<blockquote><p>A &lt;passage&gt;</p></blockquote>" +
+		"<blockquote><p>First line</p><p>second line</p></blockquote>" +
+		"</section><hr/>"
+	assert.Equal(t, expected, Highlights(b))
+	assert.Equal(t, "", Highlights(structs.Bookmark{}))
+
+	var tmpl *Template
+	got, err := tmpl.Render(b)
+	require.NoError(t, err)
+	assert.Equal(t, expected+"<p>Article</p>", got)
+
+	tmpl, err = Parse("{{.Text}}{{.HighlightsHTML}}")
+	require.NoError(t, err)
+	got, err = tmpl.Render(b)
+	require.NoError(t, err)
+	assert.Equal(t, "<p>Article</p>"+expected, got)
+}
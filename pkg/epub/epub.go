@@ -0,0 +1,363 @@
+package epub
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"embed"
+	"encoding/xml"
+	"fmt"
+	"hash/crc32"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+
+	"github.com/chuhlomin/instapaper2rss/pkg/sanitize"
+	"github.com/chuhlomin/instapaper2rss/pkg/structs"
+	"github.com/chuhlomin/instapaper2rss/pkg/xmltext"
+)
+
+//go:embed templates
+var templatesFS embed.FS
+
+var templates = template.Must(template.New("").Funcs(template.FuncMap{
+	"xml": xmlEscape,
+}).ParseFS(templatesFS, "templates/*"))
+
+const (
+	defaultTitle    = "Instapaper"
+	defaultAuthor   = "Instapaper"
+	defaultLanguage = "en"
+)
+
+// mediaTypes are the image types embedded in the book,
+// limited to EPUB core media types.
+var mediaTypes = map[string]string{
+	".gif":  "image/gif",
+	".jpg":  "image/jpeg",
+	".jpeg": "image/jpeg",
+	".png":  "image/png",
+	".webp": "image/webp",
+}
+
+// Builder builds an EPUB 3 book with one chapter per bookmark,
+// oldest first. Article HTML is sanitized with the reader-friendly policy.
+// Images archived under ImagesURL are embedded from ImagesDir;
+// other images are replaced with their alt text.
+type Builder struct {
+	Title     string
+	Author    string
+	Language  string
+	ImagesDir string
+	ImagesURL string
+}
+
+type book struct {
+	ID       string
+	Title    string
+	Author   string
+	Language string
+	Modified string
+	Chapters []chapter
+	Images   []image
+}
+
+type chapter struct {
+	ID       string
+	File     string
+	Language string
+	Title    string
+	URL      string
+	Host     string
+	Date     string
+	DateTime string
+	Body     string
+}
+
+type image struct {
+	ID        string
+	File      string
+	MediaType string
+	Data      []byte
+}
+
+func (eb Builder) Build(bookmarks []structs.Bookmark) ([]byte, error) {
+	bookmarks = append([]structs.Bookmark(nil), bookmarks...)
+	sort.SliceStable(bookmarks, func(i, j int) bool {
+		return bookmarks[i].Time < bookmarks[j].Time
+	})
+
+	bk := book{
+		ID:       bookID(bookmarks),
+		Title:    orDefault(eb.Title, defaultTitle),
+		Author:   orDefault(eb.Author, defaultAuthor),
+		Language: orDefault(eb.Language, defaultLanguage),
+	}
+
+	var modified time.Time
+	images := map[string]*image{}
+	for i, b := range bookmarks {
+		b = xmltext.Bookmark(b)
+
+		body, err := eb.body(b.Text, &bk, images)
+		if err != nil {
+			return nil, fmt.Errorf("error converting bookmark %d: %w", b.ID, err)
+		}
+
+		t := time.Unix(b.Time, 0).UTC()
+		if t.After(modified) {
+			modified = t
+		}
+
+		ch := chapter{
+			ID:       "chapter-" + strconv.Itoa(i+1),
+			File:     "chapter-" + strconv.Itoa(i+1) + ".xhtml",
+			Language: bk.Language,
+			Title:    b.FeedTitle(),
+			URL:      b.URL,
+			Date:     t.Format("2 January 2006"),
+			DateTime: t.Format(time.RFC3339),
+			Body:     body,
+		}
+		if u, err := url.Parse(b.URL); err == nil {
+			ch.Host = strings.TrimPrefix(u.Host, "www.")
+		}
+		bk.Chapters = append(bk.Chapters, ch)
+	}
+
+	if modified.IsZero() {
+		modified = time.Unix(0, 0).UTC()
+	}
+	bk.Modified = modified.Format(time.RFC3339)
+
+	return eb.write(bk, modified)
+}
+
+// body sanitizes the article HTML and embeds local images,
+// returning XHTML for the chapter.
+func (eb Builder) body(text string, bk *book, images map[string]*image) (string, error) {
+	text, _, err := sanitize.ReaderFriendly().Sanitize(text)
+	if err != nil {
+		return "", err
+	}
+
+	nodes, err := html.ParseFragment(strings.NewReader(text), &html.Node{
+		Type:     html.ElementNode,
+		Data:     "body",
+		DataAtom: atom.Body,
+	})
+	if err != nil {
+		return "", fmt.Errorf("error parsing HTML: %w", err)
+	}
+
+	root := &html.Node{Type: html.DocumentNode}
+	for _, n := range nodes {
+		root.AppendChild(n)
+	}
+
+	var imgs []*html.Node
+	collectImages(root, &imgs)
+	for _, n := range imgs {
+		img := eb.embedImage(attr(n, "src"), bk, images)
+		if img != nil {
+			setAttr(n, "src", img.File)
+			if attr(n, "alt") == "" {
+				setAttr(n, "alt", "")
+			}
+			continue
+		}
+
+		if alt := attr(n, "alt"); alt != "" {
+			n.Parent.InsertBefore(&html.Node{Type: html.TextNode, Data: alt}, n)
+		}
+		n.Parent.RemoveChild(n)
+	}
+
+	var sb strings.Builder
+	for n := root.FirstChild; n != nil; n = n.NextSibling {
+		if err := html.Render(&sb, n); err != nil {
+			return "", fmt.Errorf("error rendering HTML: %w", err)
+		}
+	}
+
+	return xmltext.Clean(sb.String()), nil
+}
+
+// embedImage adds the archived image at src to the book,
+// returning nil if it is not available locally.
+func (eb Builder) embedImage(src string, bk *book, images map[string]*image) *image {
+	if eb.ImagesDir == "" || eb.ImagesURL == "" {
+		return nil
+	}
+
+	name, ok := strings.CutPrefix(src, strings.TrimSuffix(eb.ImagesURL, "/")+"/")
+	if !ok || name == "" || strings.Contains(name, "/") {
+		return nil
+	}
+
+	if img, ok := images[name]; ok {
+		return img
+	}
+
+	mediaType, ok := mediaTypes[strings.ToLower(path.Ext(name))]
+	if !ok {
+		return nil
+	}
+
+	data, err := os.ReadFile(filepath.Join(eb.ImagesDir, name))
+	if err != nil {
+		return nil
+	}
+
+	img := &image{
+		ID:        "image-" + strconv.Itoa(len(bk.Images)+1),
+		File:      "images/" + name,
+		MediaType: mediaType,
+		Data:      data,
+	}
+	images[name] = img
+	bk.Images = append(bk.Images, *img)
+	return img
+}
+
+type file struct {
+	name     string
+	template string
+	data     any
+}
+
+func (eb Builder) write(bk book, modified time.Time) ([]byte, error) {
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+
+	// the mimetype must come first, uncompressed and without extra fields
+	mimetype := []byte("application/epub+zip")
+	fh := &zip.FileHeader{
+		Name:               "mimetype",
+		Method:             zip.Store,
+		CRC32:              crc32.ChecksumIEEE(mimetype),
+		CompressedSize64:   uint64(len(mimetype)),
+		UncompressedSize64: uint64(len(mimetype)),
+	}
+	// CreateRaw writes the header as is, so set the MS-DOS time fields
+	fh.SetModTime(modified)
+	fw, err := w.CreateRaw(fh)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := fw.Write(mimetype); err != nil {
+		return nil, err
+	}
+
+	files := []file{
+		{"META-INF/container.xml", "container.xml", bk},
+		{"OEBPS/content.opf", "content.opf", bk},
+		{"OEBPS/nav.xhtml", "nav.xhtml", bk},
+		{"OEBPS/style.css", "style.css", bk},
+	}
+	for _, ch := range bk.Chapters {
+		files = append(files, file{"OEBPS/" + ch.File, "chapter.xhtml", ch})
+	}
+
+	for _, f := range files {
+		var b bytes.Buffer
+		if err := templates.ExecuteTemplate(&b, f.template, f.data); err != nil {
+			return nil, fmt.Errorf("error rendering %s: %w", f.name, err)
+		}
+		if err := writeFile(w, f.name, b.Bytes(), modified); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, img := range bk.Images {
+		if err := writeFile(w, "OEBPS/"+img.File, img.Data, modified); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func writeFile(w *zip.Writer, name string, data []byte, modified time.Time) error {
+	fw, err := w.CreateHeader(&zip.FileHeader{
+		Name:     name,
+		Method:   zip.Deflate,
+		Modified: modified,
+	})
+	if err != nil {
+		return fmt.Errorf("error adding %s: %w", name, err)
+	}
+
+	if _, err := fw.Write(data); err != nil {
+		return fmt.Errorf("error writing %s: %w", name, err)
+	}
+	return nil
+}
+
+func collectImages(n *html.Node, images *[]*html.Node) {
+	if n.Type == html.ElementNode && n.DataAtom == atom.Img {
+		*images = append(*images, n)
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		collectImages(c, images)
+	}
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+func setAttr(n *html.Node, key, value string) {
+	for i, a := range n.Attr {
+		if a.Key == key {
+			n.Attr[i].Val = value
+			return
+		}
+	}
+	n.Attr = append(n.Attr, html.Attribute{Key: key, Val: value})
+}
+
+// bookID returns a UUID derived from the bookmark IDs,
+// so the same selection of bookmarks makes the same book.
+func bookID(bookmarks []structs.Bookmark) string {
+	h := sha256.New()
+	for _, b := range bookmarks {
+		fmt.Fprintf(h, "%d\n", b.ID)
+	}
+	sum := h.Sum(nil)
+	sum[6] = sum[6]&0x0f | 0x50 // version 5
+	sum[8] = sum[8]&0x3f | 0x80 // RFC 4122 variant
+
+	return fmt.Sprintf("urn:uuid:%x-%x-%x-%x-%x", sum[0:4], sum[4:6], sum[6:8], sum[8:10], sum[10:16])
+}
+
+func xmlEscape(s string) string {
+	var b strings.Builder
+	_ = xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+func orDefault(value, defaultValue string) string {
+	if value == "" {
+		return defaultValue
+	}
+	return value
+}
@@ -0,0 +1,134 @@
+package epub
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/chuhlomin/instapaper2rss/pkg/structs"
+)
+
+func TestBuilder_Build(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "abc.png"), []byte("png"), 0o644))
+
+	bookmarks := []structs.Bookmark{
+		{
+			ID:    2,
+			Time:  1740000000,
+			Title: "Second & last",
+			URL:   "https://www.example.com/2",
+			Text: `<p>Local <img src="https://example.com/images/abc.png"></p>` +
+				`<p>Remote <img src="https://other.example.org/x.png" alt="Remote image"></p>` +
+				`<script>alert(1)</script>`,
+		},
+		{
+			ID:    1,
+			Time:  1739202544,
+			Title: "First",
+			URL:   "https://example.com/1",
+			Text:  "<p>Tag soup<br>&nbsp;text",
+		},
+	}
+
+	builder := Builder{
+		Title:     "Reading list",
+		ImagesDir: dir,
+		ImagesURL: "https://example.com/images/",
+	}
+
+	data, err := builder.Build(bookmarks)
+	require.NoError(t, err)
+
+	again, err := builder.Build(bookmarks)
+	require.NoError(t, err)
+	assert.Equal(t, data, again, "builds are reproducible")
+
+	files := readZip(t, data)
+
+	r, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	require.NoError(t, err)
+	assert.Equal(t, "mimetype", r.File[0].Name)
+	assert.Equal(t, zip.Store, r.File[0].Method)
+	assert.Equal(t, "application/epub+zip", files["mimetype"])
+
+	assert.Contains(t, files["META-INF/container.xml"], `full-path="OEBPS/content.opf"`)
+
+	opf := files["OEBPS/content.opf"]
+	assert.Contains(t, opf, `<dc:title>Reading list</dc:title>`)
+	assert.Contains(t, opf, `<meta property="dcterms:modified">2025-02-19T21:20:00Z</meta>`)
+	assert.Contains(t, opf, `<item id="image-1" href="images/abc.png" media-type="image/png"/>`)
+	assert.Contains(t, opf, `<itemref idref="chapter-1"/>`)
+	assert.Contains(t, opf, `<itemref idref="chapter-2"/>`)
+
+	nav := files["OEBPS/nav.xhtml"]
+	assert.Contains(t, nav, `<li><a href="chapter-1.xhtml">First</a></li>`)
+	assert.Contains(t, nav, `<li><a href="chapter-2.xhtml">Second &amp; last</a></li>`)
+
+	// oldest first
+	assert.Contains(t, files["OEBPS/chapter-1.xhtml"], "<h1>First</h1>")
+	assert.Contains(t, files["OEBPS/chapter-1.xhtml"], "<p>Tag soup<br/> text</p>")
+
+	second := files["OEBPS/chapter-2.xhtml"]
+	assert.Contains(t, second, `<a href="https://www.example.com/2">example.com</a>`)
+	assert.Contains(t, second, `<time datetime="2025-02-19T21:20:00Z">19 February 2025</time>`)
+	assert.Contains(t, second, `<img src="images/abc.png" alt=""/>`)
+	assert.Contains(t, second, `<p>Remote Remote image</p>`)
+	assert.NotContains(t, second, "script")
+
+	assert.Equal(t, "png", files["OEBPS/images/abc.png"])
+
+	for name, content := range files {
+		if strings.HasSuffix(name, ".xhtml") || strings.HasSuffix(name, ".opf") || strings.HasSuffix(name, ".xml") {
+			assertWellFormed(t, name, content)
+		}
+	}
+}
+
+func TestBuilder_BuildEmpty(t *testing.T) {
+	data, err := Builder{}.Build(nil)
+	require.NoError(t, err)
+
+	files := readZip(t, data)
+	assert.Contains(t, files["OEBPS/content.opf"], `<dc:title>Instapaper</dc:title>`)
+	assert.Contains(t, files["OEBPS/content.opf"], `<dc:language>en</dc:language>`)
+}
+
+func readZip(t *testing.T, data []byte) map[string]string {
+	t.Helper()
+
+	r, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	require.NoError(t, err)
+
+	files := map[string]string{}
+	for _, f := range r.File {
+		rc, err := f.Open()
+		require.NoError(t, err)
+		b, err := io.ReadAll(rc)
+		require.NoError(t, err)
+		rc.Close()
+		files[f.Name] = string(b)
+	}
+	return files
+}
+
+func assertWellFormed(t *testing.T, name, content string) {
+	t.Helper()
+
+	d := xml.NewDecoder(strings.NewReader(content))
+	for {
+		_, err := d.Token()
+		if err == io.EOF {
+			return
+		}
+		require.NoError(t, err, name)
+	}
+}
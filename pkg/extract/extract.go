@@ -0,0 +1,315 @@
+package extract
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+
+	"github.com/chuhlomin/instapaper2rss/pkg/structs"
+)
+
+// Metadata describes an article, as found in its HTML.
+type Metadata struct {
+	Byline    string
+	Published time.Time // zero when unknown
+	SiteName  string
+	Image     string // absolute URL of the lead image
+}
+
+// maxBylineLength drops "bylines" that are whole paragraphs.
+const maxBylineLength = 100
+
+// dateLayouts are the date formats found in meta tags and time elements.
+var dateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05Z0700",
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05",
+	time.DateOnly,
+	time.RFC1123Z,
+	time.RFC1123,
+}
+
+// Article extracts metadata from article HTML using common patterns:
+// JSON-LD, Open Graph and other meta tags, bylines, time elements
+// and the first image. Relative image URLs are resolved against base.
+func Article(text, base string) (Metadata, error) {
+	doc, err := html.Parse(strings.NewReader(text))
+	if err != nil {
+		return Metadata{}, fmt.Errorf("error parsing HTML: %w", err)
+	}
+
+	baseURL, err := url.Parse(base)
+	if err != nil {
+		return Metadata{}, fmt.Errorf("error parsing base URL: %w", err)
+	}
+
+	var (
+		m     Metadata
+		found candidates
+	)
+	walk(doc, &found)
+
+	// the first pattern that has a value wins
+	m.Byline = cleanByline(first(found.ldAuthor, found.meta["author"], found.meta["article:author"],
+		found.meta["parsely-author"], found.meta["dc.creator"], found.bylineElement))
+	m.SiteName = first(found.meta["og:site_name"], found.ldPublisher, found.meta["application-name"])
+	m.Published = parseDate(first(found.ldPublished, found.meta["article:published_time"],
+		found.meta["datepublished"], found.meta["date"], found.meta["dc.date"], found.timeElement))
+	m.Image = resolve(baseURL, first(found.ldImage, found.meta["og:image"], found.meta["twitter:image"], found.firstImage))
+
+	return m, nil
+}
+
+// Bookmark saves the metadata found in the bookmark text on it,
+// resolving the lead image against the bookmark URL.
+func Bookmark(b *structs.Bookmark) error {
+	m, err := Article(b.Text, b.URL)
+	if err != nil {
+		return err
+	}
+
+	b.Byline = m.Byline
+	b.SiteName = m.SiteName
+	b.Image = m.Image
+	b.Published = 0
+	if !m.Published.IsZero() {
+		b.Published = m.Published.Unix()
+	}
+	return nil
+}
+
+// candidates are values of each pattern, in document order.
+type candidates struct {
+	meta map[string]string // by lowercase name or property
+
+	ldAuthor, ldPublisher, ldPublished, ldImage string
+
+	bylineElement string
+	timeElement   string
+	firstImage    string
+}
+
+func walk(n *html.Node, c *candidates) {
+	if n.Type == html.ElementNode {
+		switch n.DataAtom {
+		case atom.Meta:
+			key := strings.ToLower(first(attr(n, "property"), attr(n, "name"), attr(n, "itemprop")))
+			if content := strings.TrimSpace(attr(n, "content")); key != "" && content != "" {
+				if c.meta == nil {
+					c.meta = map[string]string{}
+				}
+				if _, ok := c.meta[key]; !ok {
+					c.meta[key] = content
+				}
+			}
+
+		case atom.Script:
+			if strings.EqualFold(attr(n, "type"), "application/ld+json") && n.FirstChild != nil {
+				c.jsonLD(n.FirstChild.Data)
+			}
+			return
+
+		case atom.Time:
+			if c.timeElement == "" || attr(n, "itemprop") == "datePublished" || hasAttr(n, "pubdate") {
+				if v := first(attr(n, "datetime"), textContent(n)); v != "" {
+					c.timeElement = v
+				}
+			}
+
+		case atom.Img:
+			if c.firstImage == "" && !strings.HasPrefix(attr(n, "src"), "data:") {
+				c.firstImage = strings.TrimSpace(attr(n, "src"))
+			}
+		}
+
+		if c.bylineElement == "" && isByline(n) {
+			c.bylineElement = collapse(textContent(n))
+		}
+	}
+
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		walk(child, c)
+	}
+}
+
+// isByline reports whether the element holds the author's name.
+func isByline(n *html.Node) bool {
+	if attr(n, "rel") == "author" || attr(n, "itemprop") == "author" {
+		return true
+	}
+
+	for _, class := range strings.Fields(strings.ToLower(attr(n, "class"))) {
+		if class == "byline" || class == "author" || class == "author-name" || class == "p-author" {
+			return true
+		}
+	}
+	return false
+}
+
+// jsonLD reads Article-like objects from a JSON-LD script.
+func (c *candidates) jsonLD(data string) {
+	var v any
+	if err := json.Unmarshal([]byte(data), &v); err != nil {
+		return
+	}
+
+	var objects []map[string]any
+	var collect func(v any)
+	collect = func(v any) {
+		switch v := v.(type) {
+		case []any:
+			for _, item := range v {
+				collect(item)
+			}
+		case map[string]any:
+			if graph, ok := v["@graph"]; ok {
+				collect(graph)
+				return
+			}
+			objects = append(objects, v)
+		}
+	}
+	collect(v)
+
+	for _, o := range objects {
+		if !strings.HasSuffix(name(o["@type"]), "Article") && name(o["@type"]) != "BlogPosting" {
+			continue
+		}
+
+		if c.ldAuthor == "" {
+			c.ldAuthor = name(o["author"])
+		}
+		if c.ldPublisher == "" {
+			c.ldPublisher = name(o["publisher"])
+		}
+		if c.ldPublished == "" {
+			c.ldPublished = name(o["datePublished"])
+		}
+		if c.ldImage == "" {
+			c.ldImage = imageURL(o["image"])
+		}
+	}
+}
+
+// name returns a string, the name of an object, or the first of a list.
+func name(v any) string {
+	switch v := v.(type) {
+	case string:
+		return strings.TrimSpace(v)
+	case map[string]any:
+		return name(v["name"])
+	case []any:
+		names := make([]string, 0, len(v))
+		for _, item := range v {
+			if n := name(item); n != "" {
+				names = append(names, n)
+			}
+		}
+		return strings.Join(names, ", ")
+	}
+	return ""
+}
+
+func imageURL(v any) string {
+	switch v := v.(type) {
+	case string:
+		return strings.TrimSpace(v)
+	case map[string]any:
+		return imageURL(v["url"])
+	case []any:
+		if len(v) > 0 {
+			return imageURL(v[0])
+		}
+	}
+	return ""
+}
+
+// cleanByline removes a leading "By" and drops text too long to be a name.
+func cleanByline(s string) string {
+	s = collapse(s)
+	if len(s) > 3 && strings.EqualFold(s[:3], "by ") {
+		s = strings.TrimSpace(s[3:])
+	}
+	if len(s) > maxBylineLength || strings.HasPrefix(s, "http://") || strings.HasPrefix(s, "https://") {
+		return ""
+	}
+	return s
+}
+
+func parseDate(s string) time.Time {
+	s = strings.TrimSpace(s)
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+func resolve(base *url.URL, ref string) string {
+	if ref == "" {
+		return ""
+	}
+
+	u, err := url.Parse(ref)
+	if err != nil {
+		return ""
+	}
+
+	u = base.ResolveReference(u)
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return ""
+	}
+	return u.String()
+}
+
+func first(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+func collapse(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+func textContent(n *html.Node) string {
+	if n.Type == html.TextNode {
+		return n.Data
+	}
+
+	var sb strings.Builder
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		sb.WriteString(textContent(c))
+		sb.WriteString(" ")
+	}
+	return sb.String()
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+func hasAttr(n *html.Node, key string) bool {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return true
+		}
+	}
+	return false
+}
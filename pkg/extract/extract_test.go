@@ -0,0 +1,73 @@
+package extract
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestArticle(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected Metadata
+	}{
+		{
+			name: "jsonld",
+			expected: Metadata{
+				Byline:    "Ada Lovelace, Alan Turing",
+				Published: time.Date(2024, 11, 5, 9, 30, 0, 0, time.FixedZone("", 3600)),
+				SiteName:  "Example Engineering",
+				Image:     "https://example.com/images/lead.jpg",
+			},
+		},
+		{
+			name: "meta",
+			expected: Metadata{
+				Byline:    "Grace Hopper",
+				Published: time.Date(2025, 1, 20, 8, 0, 0, 0, time.UTC),
+				SiteName:  "The Weekly Blog",
+				Image:     "https://cdn.example.com/card.png",
+			},
+		},
+		{
+			name: "body",
+			expected: Metadata{
+				Byline:    "Margaret Hamilton",
+				Published: time.Date(2023, 7, 1, 10, 0, 0, 0, time.UTC),
+				Image:     "https://example.com/blog/img/figure-1.png",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := os.ReadFile("testdata/" + tt.name + ".html")
+			require.NoError(t, err)
+
+			got, err := Article(string(b), "https://example.com/blog/post")
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected.Byline, got.Byline)
+			assert.True(t, tt.expected.Published.Equal(got.Published), "published %s", got.Published)
+			assert.Equal(t, tt.expected.SiteName, got.SiteName)
+			assert.Equal(t, tt.expected.Image, got.Image)
+		})
+	}
+}
+
+func TestArticle_Empty(t *testing.T) {
+	got, err := Article("<p>Just text, <a href=\"/x\" class=\"author\">https://example.com/a-very-long-link</a></p>", "https://example.com/")
+	require.NoError(t, err)
+	assert.Equal(t, Metadata{}, got)
+
+	_, err = Article("<p>text</p>", "%zz")
+	assert.ErrorContains(t, err, "error parsing base URL")
+}
+
+func TestParseDate(t *testing.T) {
+	assert.Equal(t, time.Date(2025, 2, 10, 0, 0, 0, 0, time.UTC), parseDate("2025-02-10"))
+	assert.Equal(t, time.Date(2025, 2, 10, 12, 30, 0, 0, time.UTC), parseDate(" 2025-02-10T12:30:00 "))
+	assert.True(t, parseDate("next Tuesday").IsZero())
+}
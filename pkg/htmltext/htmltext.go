@@ -0,0 +1,165 @@
+package htmltext
+
+import (
+	"encoding/xml"
+	"errors"
+	"io"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// skipped are elements whose content is not readable text.
+var skipped = map[atom.Atom]bool{
+	atom.Head:     true,
+	atom.Script:   true,
+	atom.Style:    true,
+	atom.Noscript: true,
+	atom.Template: true,
+	atom.Iframe:   true,
+	atom.Svg:      true,
+	atom.Math:     true,
+}
+
+// blocks are elements that separate paragraphs of text.
+var blocks = map[atom.Atom]bool{
+	atom.Address:    true,
+	atom.Article:    true,
+	atom.Aside:      true,
+	atom.Blockquote: true,
+	atom.Br:         true,
+	atom.Dd:         true,
+	atom.Div:        true,
+	atom.Dl:         true,
+	atom.Dt:         true,
+	atom.Figcaption: true,
+	atom.Figure:     true,
+	atom.Footer:     true,
+	atom.H1:         true,
+	atom.H2:         true,
+	atom.H3:         true,
+	atom.H4:         true,
+	atom.H5:         true,
+	atom.H6:         true,
+	atom.Header:     true,
+	atom.Hr:         true,
+	atom.Li:         true,
+	atom.Main:       true,
+	atom.Ol:         true,
+	atom.P:          true,
+	atom.Pre:        true,
+	atom.Section:    true,
+	atom.Table:      true,
+	atom.Td:         true,
+	atom.Th:         true,
+	atom.Tr:         true,
+	atom.Ul:         true,
+}
+
+// PlainText returns the readable text of an HTML document or fragment.
+// Paragraphs are separated by a blank line, other whitespace is collapsed.
+func PlainText(s string) string {
+	var (
+		sb    strings.Builder
+		skip  int
+		space bool // whitespace pending before the next word
+		block bool // paragraph break pending before the next word
+	)
+
+	z := html.NewTokenizer(strings.NewReader(s))
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return sb.String()
+
+		case html.StartTagToken, html.SelfClosingTagToken, html.EndTagToken:
+			tt := z.Token()
+			if skipped[tt.DataAtom] {
+				if tt.Type == html.StartTagToken {
+					skip++
+				} else if tt.Type == html.EndTagToken && skip > 0 {
+					skip--
+				}
+				continue
+			}
+			if blocks[tt.DataAtom] {
+				block = true
+			}
+
+		case html.TextToken:
+			if skip > 0 {
+				continue
+			}
+
+			// text is split into tokens at tags, so whitespace at the edges matters
+			text := string(z.Text())
+			if r, _ := utf8.DecodeRuneInString(text); unicode.IsSpace(r) {
+				space = true
+			}
+
+			for i, word := range strings.Fields(text) {
+				if sb.Len() > 0 {
+					switch {
+					case block:
+						sb.WriteString("\n\n")
+					case space || i > 0:
+						sb.WriteByte(' ')
+					}
+				}
+				sb.WriteString(word)
+				space, block = false, false
+			}
+
+			if r, _ := utf8.DecodeLastRuneInString(text); unicode.IsSpace(r) {
+				space = true
+			}
+		}
+	}
+}
+
+// Excerpt returns the beginning of the text of an HTML document
+// no longer than maxLen characters, cut at a word boundary.
+func Excerpt(s string, maxLen int) string {
+	text := strings.Join(strings.Fields(PlainText(s)), " ")
+	if utf8.RuneCountInString(text) <= maxLen {
+		return text
+	}
+
+	runes := []rune(text)[:maxLen]
+	if i := strings.LastIndexFunc(string(runes), unicode.IsSpace); i > 0 {
+		return strings.TrimRightFunc(string(runes)[:i], unicode.IsPunct) + "…"
+	}
+	return string(runes) + "…"
+}
+
+// IsXHTML reports whether the HTML fragment is well-formed XML
+// that can be embedded as XHTML: balanced tags, quoted attributes,
+// no entities other than the predefined XML ones
+// and no document-level elements or declarations.
+func IsXHTML(s string) bool {
+	d := xml.NewDecoder(strings.NewReader("<div>" + s + "</div>"))
+	d.Strict = true
+
+	for {
+		t, err := d.Token()
+		if errors.Is(err, io.EOF) {
+			return true
+		}
+		if err != nil {
+			return false
+		}
+
+		switch t := t.(type) {
+		case xml.StartElement:
+			switch strings.ToLower(t.Name.Local) {
+			case "html", "head", "body":
+				return false
+			}
+		case xml.ProcInst, xml.Directive:
+			return false
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package htmltext
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPlainText(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{
+			name: "inline elements",
+			in:   "Hello, <b>world</b>! <a href=\"#\">Link</a>text",
+			want: "Hello, world! Linktext",
+		},
+		{
+			name: "paragraphs",
+			in:   "<h1>Title</h1>\n<p>First   paragraph\nwith a line break.</p><p>Second<br>line</p>",
+			want: "Title\n\nFirst paragraph with a line break.\n\nSecond\n\nline",
+		},
+		{
+			name: "document",
+			in:   "<html><head><title>Page</title><style>p{}</style></head><body><p>Body &amp; soul</p><script>alert(1)</script></body></html>",
+			want: "Body & soul",
+		},
+		{
+			name: "empty",
+			in:   "",
+			want: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, PlainText(tt.in))
+		})
+	}
+}
+
+func TestExcerpt(t *testing.T) {
+	assert.Equal(t, "Short text", Excerpt("<p>Short</p> text", 20))
+	assert.Equal(t, "First paragraph…", Excerpt("<p>First paragraph.</p><p>Second one</p>", 20))
+	assert.Equal(t, "Привет…", Excerpt("<p>Привет, мир</p>", 9))
+	assert.Equal(t, "abcde…", Excerpt("abcdefgh", 5))
+}
+
+func TestIsXHTML(t *testing.T) {
+	assert.True(t, IsXHTML("<p>Text with <img src=\"a.png\"/> &amp; <br/></p>"))
+	assert.True(t, IsXHTML("plain text"))
+	assert.False(t, IsXHTML("<p>Unclosed"))
+	assert.False(t, IsXHTML("<p>Line<br>break</p>"))
+	assert.False(t, IsXHTML("<p>&nbsp;</p>"))
+	assert.False(t, IsXHTML("<img src=a.png/>"))
+	assert.False(t, IsXHTML("<html><body><p>Document</p></body></html>"))
+}
@@ -0,0 +1,295 @@
+package images
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+
+	"github.com/chuhlomin/instapaper2rss/pkg/atomicfile"
+)
+
+// DefaultMaxSize is the largest image downloaded when MaxSize is not set.
+const DefaultMaxSize = 5 << 20
+
+// extensions maps allowed image types to file extensions.
+// SVG is left out as it can carry scripts.
+var extensions = map[string]string{
+	"image/avif": ".avif",
+	"image/gif":  ".gif",
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/webp": ".webp",
+}
+
+// Archiver downloads images referenced in article HTML into Dir,
+// naming them by the SHA-256 of their content, and points the
+// <img> tags at the copies published under BaseURL.
+// Without Client, images are only downloaded from public addresses,
+// so articles can't make the archiver fetch from the local network.
+type Archiver struct {
+	Dir     string
+	BaseURL string
+	MaxSize int64        // bytes, DefaultMaxSize if zero
+	Client  *http.Client // public addresses only, with a timeout, if nil
+
+	files []string
+	cache map[string]string // original URL to public URL
+}
+
+// Archive downloads the images of the HTML fragment and returns
+// the fragment with rewritten sources. Images that fail to download,
+// are too large or of other types keep pointing at the original.
+func (a *Archiver) Archive(text string) (string, error) {
+	nodes, err := html.ParseFragment(strings.NewReader(text), &html.Node{
+		Type:     html.ElementNode,
+		Data:     "body",
+		DataAtom: atom.Body,
+	})
+	if err != nil {
+		return "", fmt.Errorf("error parsing HTML: %w", err)
+	}
+
+	var sb strings.Builder
+	for _, n := range nodes {
+		a.rewrite(n)
+		if err := html.Render(&sb, n); err != nil {
+			return "", fmt.Errorf("error rendering HTML: %w", err)
+		}
+	}
+
+	return sb.String(), nil
+}
+
+// Files returns the paths of images written so far.
+func (a *Archiver) Files() []string {
+	return a.files
+}
+
+func (a *Archiver) rewrite(n *html.Node) {
+	if n.Type == html.ElementNode && n.DataAtom == atom.Img {
+		a.rewriteImg(n)
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		a.rewrite(c)
+	}
+}
+
+func (a *Archiver) rewriteImg(n *html.Node) {
+	i := attrIndex(n, "src")
+	if i < 0 {
+		return
+	}
+
+	archived := a.ArchiveURL(n.Attr[i].Val)
+	if archived == n.Attr[i].Val {
+		return
+	}
+
+	n.Attr[i].Val = archived
+
+	// srcset candidates point at the original site
+	if j := attrIndex(n, "srcset"); j >= 0 {
+		n.Attr = append(n.Attr[:j], n.Attr[j+1:]...)
+	}
+}
+
+// ArchiveURL downloads the image at src, such as a bookmark's lead image,
+// and returns its public URL. Like in Archive, images that are not
+// archived keep the original URL.
+func (a *Archiver) ArchiveURL(src string) string {
+	if !strings.HasPrefix(src, "http://") && !strings.HasPrefix(src, "https://") ||
+		a.BaseURL != "" && strings.HasPrefix(src, a.BaseURL) {
+		return src
+	}
+
+	archived, err := a.archive(src)
+	if err != nil {
+		log.Printf("Image %s not archived: %v", src, err)
+		return src
+	}
+	return archived
+}
+
+// archive downloads the image unless it was seen before
+// and returns its public URL.
+func (a *Archiver) archive(src string) (string, error) {
+	if archived, ok := a.cache[src]; ok {
+		return archived, nil
+	}
+
+	data, err := a.download(src)
+	if err != nil {
+		return "", err
+	}
+
+	// the server's Content-Type is not trusted, e.g. an HTML page
+	// served as image/png must not be published as an image
+	contentType := sniff(data)
+	ext, ok := extensions[contentType]
+	if !ok {
+		return "", fmt.Errorf("type %q is not allowed", contentType)
+	}
+
+	sum := sha256.Sum256(data)
+	name := hex.EncodeToString(sum[:]) + ext
+	path := filepath.Join(a.Dir, name)
+
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		if err := atomicfile.WriteFile(path, data); err != nil {
+			return "", err
+		}
+		a.files = append(a.files, path)
+	}
+
+	archived, err := url.JoinPath(a.BaseURL, name)
+	if err != nil {
+		return "", fmt.Errorf("error joining URL: %w", err)
+	}
+
+	if a.cache == nil {
+		a.cache = map[string]string{}
+	}
+	a.cache[src] = archived
+
+	return archived, nil
+}
+
+// download returns the image.
+func (a *Archiver) download(src string) ([]byte, error) {
+	client := a.Client
+	if client == nil {
+		client = publicClient
+	}
+
+	maxSize := a.MaxSize
+	if maxSize == 0 {
+		maxSize = DefaultMaxSize
+	}
+
+	resp, err := client.Get(src)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	if resp.ContentLength > maxSize {
+		return nil, fmt.Errorf("size %d exceeds %d bytes", resp.ContentLength, maxSize)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("error reading body: %w", err)
+	}
+	if int64(len(data)) > maxSize {
+		return nil, fmt.Errorf("size exceeds %d bytes", maxSize)
+	}
+
+	return data, nil
+}
+
+// sniff returns the media type of the image content.
+// http.DetectContentType doesn't know AVIF, an ISO BMFF file
+// with an "avif" or "avis" brand in its ftyp box.
+func sniff(data []byte) string {
+	contentType, _, _ := mime.ParseMediaType(http.DetectContentType(data))
+	if contentType != "application/octet-stream" || len(data) < 12 || string(data[4:8]) != "ftyp" {
+		return contentType
+	}
+
+	size := int(binary.BigEndian.Uint32(data[:4]))
+	if size < 16 || size > len(data) {
+		size = min(len(data), 64)
+	}
+
+	// major brand, then compatible brands after the minor version
+	brands := data[8:size]
+	for i := 0; i+4 <= len(brands); i += 4 {
+		if i == 4 {
+			continue
+		}
+		if b := string(brands[i : i+4]); b == "avif" || b == "avis" {
+			return "image/avif"
+		}
+	}
+	return contentType
+}
+
+// publicClient downloads images from public addresses only.
+var publicClient = newClient(checkPublic)
+
+// newClient returns a client connecting only to addresses passing check.
+// Addresses are checked when connecting, after name resolution,
+// so hosts resolving to private addresses and redirects to them are refused.
+func newClient(check func(address string) error) *http.Client {
+	return &http.Client{
+		Timeout: 30 * time.Second,
+		Transport: &http.Transport{
+			// a proxy would be the only address checked
+			Proxy: nil,
+			DialContext: (&net.Dialer{
+				Timeout: 10 * time.Second,
+				Control: func(_, address string, _ syscall.RawConn) error {
+					return check(address)
+				},
+			}).DialContext,
+			TLSHandshakeTimeout:   10 * time.Second,
+			ResponseHeaderTimeout: 20 * time.Second,
+		},
+	}
+}
+
+// checkPublic returns an error unless the "host:port" address
+// is a public unicast IP address.
+func checkPublic(address string) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return err
+	}
+	ip = ip.Unmap()
+
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() ||
+		sharedAddressSpace.Contains(ip) {
+		return fmt.Errorf("address %s is not public", ip)
+	}
+	return nil
+}
+
+// sharedAddressSpace is used for carrier-grade NAT (RFC 6598).
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+func attrIndex(n *html.Node, key string) int {
+	for i, attr := range n.Attr {
+		if attr.Namespace == "" && attr.Key == key {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,216 @@
+package images
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// png is the 8-byte PNG signature, enough for content sniffing.
+var png = []byte("\x89PNG\r\n\x1a\n")
+
+func TestArchiver_Archive(t *testing.T) {
+	requests := map[string]int{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests[r.URL.Path]++
+
+		switch r.URL.Path {
+		case "/photo.png":
+			w.Header().Set("Content-Type", "image/png")
+			_, _ = w.Write(png)
+		case "/sniffed":
+			_, _ = w.Write(png)
+		case "/mislabeled.jpg":
+			w.Header().Set("Content-Type", "image/jpeg")
+			_, _ = w.Write(png)
+		case "/spoofed.png":
+			w.Header().Set("Content-Type", "image/png")
+			_, _ = w.Write([]byte("<html><script></script></html>"))
+		case "/large.jpg":
+			w.Header().Set("Content-Type", "image/jpeg")
+			_, _ = w.Write(bytes.Repeat([]byte{0xff}, 100))
+		case "/page.html":
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			_, _ = w.Write([]byte("<html></html>"))
+		case "/icon.svg":
+			w.Header().Set("Content-Type", "image/svg+xml")
+			_, _ = w.Write([]byte("<svg></svg>"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	a := &Archiver{
+		Dir:     dir,
+		BaseURL: "https://feeds.example.com/images/",
+		MaxSize: 64,
+		Client:  server.Client(),
+	}
+
+	sum := sha256.Sum256(png)
+	name := hex.EncodeToString(sum[:]) + ".png"
+	archived := "https://feeds.example.com/images/" + name
+
+	got, err := a.Archive(`<p>` +
+		`<img src="` + server.URL + `/photo.png" srcset="` + server.URL + `/photo.png 1x" alt="Photo">` +
+		`<img src="` + server.URL + `/photo.png">` +
+		`<img src="` + server.URL + `/sniffed">` +
+		`<img src="` + server.URL + `/mislabeled.jpg">` +
+		`<img src="` + server.URL + `/spoofed.png">` +
+		`<img src="` + server.URL + `/large.jpg">` +
+		`<img src="` + server.URL + `/page.html">` +
+		`<img src="` + server.URL + `/icon.svg">` +
+		`<img src="` + server.URL + `/missing.png">` +
+		`<img src="https://feeds.example.com/images/local.png">` +
+		`<img src="data:image/gif;base64,R0lGOD">` +
+		`</p>`)
+	require.NoError(t, err)
+
+	assert.Equal(t, `<p>`+
+		`<img src="`+archived+`" alt="Photo"/>`+
+		`<img src="`+archived+`"/>`+
+		`<img src="`+archived+`"/>`+
+		`<img src="`+archived+`"/>`+
+		`<img src="`+server.URL+`/spoofed.png"/>`+
+		`<img src="`+server.URL+`/large.jpg"/>`+
+		`<img src="`+server.URL+`/page.html"/>`+
+		`<img src="`+server.URL+`/icon.svg"/>`+
+		`<img src="`+server.URL+`/missing.png"/>`+
+		`<img src="https://feeds.example.com/images/local.png"/>`+
+		`<img src="data:image/gif;base64,R0lGOD"/>`+
+		`</p>`, got)
+
+	assert.Equal(t, 1, requests["/photo.png"], "repeated images are downloaded once")
+	assert.Equal(t, []string{filepath.Join(dir, name)}, a.Files())
+
+	data, err := os.ReadFile(filepath.Join(dir, name))
+	require.NoError(t, err)
+	assert.Equal(t, png, data)
+}
+
+func TestArchiver_ArchiveExisting(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		_, _ = w.Write(png)
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	sum := sha256.Sum256(png)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, hex.EncodeToString(sum[:])+".png"), png, 0o644))
+
+	a := &Archiver{Dir: dir, BaseURL: "https://feeds.example.com/images", Client: server.Client()}
+	got, err := a.Archive(`<img src="` + server.URL + `/a.png">`)
+	require.NoError(t, err)
+
+	assert.Equal(t, `<img src="https://feeds.example.com/images/`+hex.EncodeToString(sum[:])+`.png"/>`, got)
+	assert.Empty(t, a.Files(), "existing files are not rewritten")
+}
+
+func TestArchiver_ArchiveURL(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/lead.png" {
+			http.NotFound(w, r)
+			return
+		}
+		requests++
+		_, _ = w.Write(png)
+	}))
+	defer server.Close()
+
+	a := &Archiver{Dir: t.TempDir(), BaseURL: "https://feeds.example.com/images", Client: server.Client()}
+	_, err := a.Archive(`<img src="` + server.URL + `/lead.png">`)
+	require.NoError(t, err)
+
+	sum := sha256.Sum256(png)
+	archived := "https://feeds.example.com/images/" + hex.EncodeToString(sum[:]) + ".png"
+	assert.Equal(t, archived, a.ArchiveURL(server.URL+"/lead.png"))
+	assert.Equal(t, 1, requests, "images in the text are not downloaded again")
+
+	assert.Equal(t, archived, a.ArchiveURL(archived))
+	assert.Equal(t, "data:image/gif;base64,R0lGOD", a.ArchiveURL("data:image/gif;base64,R0lGOD"))
+	assert.Equal(t, server.URL+"/missing.png", a.ArchiveURL(server.URL+"/missing.png"),
+		"images that fail to download keep their URL")
+}
+
+func TestArchiver_ArchivePrivate(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Content-Type", "image/png")
+		_, _ = w.Write(png)
+	}))
+	defer server.Close()
+
+	// without a Client, only public addresses are fetched
+	a := &Archiver{Dir: t.TempDir(), BaseURL: "https://feeds.example.com/images"}
+	text := `<img src="` + server.URL + `/a.png">`
+
+	got, err := a.Archive(text)
+	require.NoError(t, err)
+	assert.Equal(t, `<img src="`+server.URL+`/a.png"/>`, got)
+	assert.Zero(t, requests)
+	assert.Empty(t, a.Files())
+
+	// redirects to private addresses are refused too
+	redirect := httptest.NewServer(http.RedirectHandler(server.URL+"/a.png", http.StatusFound))
+	defer redirect.Close()
+
+	public := strings.TrimPrefix(redirect.URL, "http://")
+	a.Client = newClient(func(address string) error {
+		if address == public {
+			return nil
+		}
+		return checkPublic(address)
+	})
+
+	got, err = a.Archive(`<img src="` + redirect.URL + `/a.png">`)
+	require.NoError(t, err)
+	assert.Equal(t, `<img src="`+redirect.URL+`/a.png"/>`, got)
+	assert.Zero(t, requests)
+}
+
+func TestCheckPublic(t *testing.T) {
+	for _, address := range []string{
+		"127.0.0.1:80",
+		"[::1]:443",
+		"10.0.0.1:80",
+		"172.16.5.4:80",
+		"192.168.1.1:80",
+		"169.254.169.254:80",
+		"100.64.0.1:80",
+		"0.0.0.0:80",
+		"[fe80::1]:80",
+		"[fd00::1]:80",
+		"[::ffff:127.0.0.1]:80",
+	} {
+		assert.Error(t, checkPublic(address), address)
+	}
+
+	for _, address := range []string{"93.184.215.14:443", "[2606:2800:21f:cb07:6820:80da:af6b:8b2c]:443"} {
+		assert.NoError(t, checkPublic(address), address)
+	}
+}
+
+func TestSniff(t *testing.T) {
+	avif := []byte("\x00\x00\x00\x1cftypavif\x00\x00\x00\x00avifmif1miaf")
+	assert.Equal(t, "image/avif", sniff(avif))
+
+	heic := []byte("\x00\x00\x00\x18ftypheic\x00\x00\x00\x00mif1heic")
+	assert.Equal(t, "application/octet-stream", sniff(heic))
+
+	assert.Equal(t, "image/png", sniff(png))
+	assert.Equal(t, "text/html", sniff([]byte("<html></html>")))
+}
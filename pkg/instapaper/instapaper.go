@@ -19,21 +19,25 @@ import (
 )
 
 type Item struct {
-	Type        string `json:"type"`
-	Username    string `json:"username"`     // user
-	URL         string `json:"url"`          // bookmark
-	Title       string `json:"title"`        // bookmark
-	Description string `json:"description"`  // bookmark
-	Hash        string `json:"hash"`         // bookmark
-	Text        string `json:"text"`         // highlight
-	Message     string `json:"message"`      // error
-	Tags        []Tag  `json:"tags"`         // bookmark
-	Time        int64  `json:"time"`         // highlight
-	UserID      int    `json:"user_id"`      // user
-	BookmarkID  int    `json:"bookmark_id"`  // bookmark
-	HighlightID int    `json:"highlight_id"` // highlight
-	Position    int    `json:"position"`     // highlight
-	ErrorCode   int    `json:"error_code"`   // error
+	Type              string  `json:"type"`
+	Username          string  `json:"username"`           // user
+	URL               string  `json:"url"`                // bookmark
+	Title             string  `json:"title"`              // bookmark
+	Description       string  `json:"description"`        // bookmark
+	Hash              string  `json:"hash"`               // bookmark
+	Starred           string  `json:"starred"`            // bookmark, "0" or "1"
+	Text              string  `json:"text"`               // highlight
+	Message           string  `json:"message"`            // error
+	Tags              []Tag   `json:"tags"`               // bookmark
+	Time              int64   `json:"time"`               // highlight
+	ProgressTimestamp int64   `json:"progress_timestamp"` // bookmark
+	Progress          float64 `json:"progress"`           // bookmark
+	UserID            int     `json:"user_id"`            // user
+	FolderID          int     `json:"folder_id"`          // folder
+	BookmarkID        int     `json:"bookmark_id"`        // bookmark
+	HighlightID       int     `json:"highlight_id"`       // highlight
+	Position          int     `json:"position"`           // highlight
+	ErrorCode         int     `json:"error_code"`         // error
 }
 
 type Tag struct {
@@ -84,6 +88,7 @@ type Client struct {
 	userAgent      string
 	timeout        time.Duration
 	retryConfig    RetryConfig
+	requestCount   int
 }
 
 func NewClient(consumerKey, consumerSecret string, options ...Option) (*Client, error) {
@@ -177,6 +182,26 @@ func (c *Client) GetBookmarks(params map[string]string) ([]Item, error) {
 	return response, err
 }
 
+// GetFolders returns the user's folders as items of type "folder".
+func (c *Client) GetFolders() ([]Item, error) {
+	resp, err := c.callAPI("folders/list", nil)
+	if err != nil {
+		return nil, fmt.Errorf("HTTP request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	var response []Item
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return response, nil
+}
+
 func (c *Client) GetBookmarkText(bookmarkID int) (string, error) {
 	resp, err := c.callAPI("bookmarks/get_text", map[string]string{
 		"bookmark_id": strconv.Itoa(bookmarkID),
@@ -268,6 +293,7 @@ func (c *Client) doWithRetry(req *http.Request) (*http.Response, error) {
 			}
 		}
 
+		c.requestCount++
 		resp, err = c.httpClient.Do(req)
 		if err == nil && resp != nil && !c.retryConfig.ShouldRetry(resp, err) {
 			break
@@ -286,6 +312,12 @@ func (c *Client) doWithRetry(req *http.Request) (*http.Response, error) {
 	return resp, err
 }
 
+// RequestCount returns the number of HTTP requests sent to the API,
+// including retries.
+func (c *Client) RequestCount() int {
+	return c.requestCount
+}
+
 func (c *Client) generateSignature(req *http.Request, reqParams, oauthParams map[string]string) (string, error) {
 	if req == nil {
 		return "", fmt.Errorf("request is nil")
@@ -0,0 +1,112 @@
+package jsonfeed
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"io"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/chuhlomin/instapaper2rss/pkg/entry"
+	"github.com/chuhlomin/instapaper2rss/pkg/structs"
+)
+
+const version = "https://jsonfeed.org/version/1.1"
+
+// Feed is a JSON Feed 1.1 document, see https://www.jsonfeed.org/version/1.1/
+type Feed struct {
+	Version     string `json:"version"`
+	Title       string `json:"title"`
+	HomePageURL string `json:"home_page_url,omitempty"`
+	FeedURL     string `json:"feed_url,omitempty"`
+	Items       []Item `json:"items"`
+}
+
+type Item struct {
+	ID            string   `json:"id"`
+	Authors       []Author `json:"authors,omitempty"`
+	URL           string   `json:"url,omitempty"`
+	ExternalURL   string   `json:"external_url,omitempty"`
+	Title         string   `json:"title,omitempty"`
+	ContentHTML   string   `json:"content_html,omitempty"`
+	Summary       string   `json:"summary,omitempty"`
+	DatePublished string   `json:"date_published,omitempty"`
+	Tags          []string `json:"tags,omitempty"`
+	Language      string   `json:"language,omitempty"`
+	Image         string   `json:"image,omitempty"`
+}
+
+type Author struct {
+	Name string `json:"name"`
+}
+
+// FeedBuilder builds a JSON Feed.
+// Item URLs point to the Instapaper reader,
+// external URLs to the original article.
+// Without Entry, the item content is the article text.
+type FeedBuilder struct {
+	Title       string
+	HomePageURL string
+	FeedURL     string
+	Entry       *entry.Template
+}
+
+// Build returns the feed with items newest first.
+func (fb FeedBuilder) Build(bookmarks []structs.Bookmark) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := fb.BuildTo(&buf, bookmarks); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// BuildTo writes the feed to w.
+func (fb FeedBuilder) BuildTo(w io.Writer, bookmarks []structs.Bookmark) error {
+	bookmarks = slices.Clone(bookmarks)
+	slices.SortStableFunc(bookmarks, func(a, b structs.Bookmark) int {
+		return cmp.Or(cmp.Compare(b.Time, a.Time), cmp.Compare(b.ID, a.ID))
+	})
+
+	feed := Feed{
+		Version:     version,
+		Title:       fb.Title,
+		HomePageURL: fb.HomePageURL,
+		FeedURL:     fb.FeedURL,
+		Items:       make([]Item, len(bookmarks)),
+	}
+
+	if feed.Title == "" {
+		feed.Title = "Instapaper"
+	}
+
+	for i, b := range bookmarks {
+		content, err := fb.Entry.Render(b)
+		if err != nil {
+			return err
+		}
+
+		feed.Items[i] = Item{
+			ID:            strconv.Itoa(b.ID),
+			URL:           "https://www.instapaper.com/read/" + strconv.Itoa(b.ID),
+			ExternalURL:   b.URL,
+			Title:         b.FeedTitle(),
+			ContentHTML:   content,
+			Summary:       b.FeedSummary(),
+			DatePublished: time.Unix(b.Time, 0).Format(time.RFC3339),
+			Tags:          b.Tags,
+			Language:      b.Language,
+			Image:         b.Image,
+		}
+
+		if b.Byline != "" {
+			feed.Items[i].Authors = []Author{{Name: b.Byline}}
+		}
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	return enc.Encode(feed)
+}
@@ -0,0 +1,96 @@
+package jsonfeed
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/chuhlomin/instapaper2rss/pkg/entry"
+	"github.com/chuhlomin/instapaper2rss/pkg/structs"
+)
+
+func build(t *testing.T, fb FeedBuilder, bookmarks []structs.Bookmark) Feed {
+	t.Helper()
+
+	b, err := fb.Build(bookmarks)
+	require.NoError(t, err)
+
+	var feed Feed
+	require.NoError(t, json.Unmarshal(b, &feed))
+	return feed
+}
+
+func TestFeedBuilder_Build(t *testing.T) {
+	feed := build(t, FeedBuilder{FeedURL: "https://example.com/feed.json"}, []structs.Bookmark{
+		{
+			ID:          1,
+			Time:        1739202544,
+			Title:       "Test Bookmark",
+			URL:         "https://example.com/1",
+			Text:        "<p>Test content</p>",
+			Description: "Short description",
+			Tags:        []string{"go", "json"},
+			Byline:      "Jane Doe",
+			Image:       "https://example.com/1.png",
+			Language:    "en",
+			ReadingTime: 3,
+			Starred:     true,
+		},
+	})
+
+	assert.Equal(t, "https://jsonfeed.org/version/1.1", feed.Version)
+	assert.Equal(t, "Instapaper", feed.Title)
+	assert.Equal(t, "https://example.com/feed.json", feed.FeedURL)
+	require.Len(t, feed.Items, 1)
+
+	assert.Equal(t, Item{
+		ID:            "1",
+		Authors:       []Author{{Name: "Jane Doe"}},
+		URL:           "https://www.instapaper.com/read/1",
+		ExternalURL:   "https://example.com/1",
+		Title:         "★ Test Bookmark",
+		ContentHTML:   "<p>Test content</p>",
+		Summary:       "3 min read · Short description",
+		DatePublished: time.Unix(1739202544, 0).Format(time.RFC3339),
+		Tags:          []string{"go", "json"},
+		Language:      "en",
+		Image:         "https://example.com/1.png",
+	}, feed.Items[0])
+}
+
+func TestFeedBuilder_BuildEntryTemplate(t *testing.T) {
+	tmpl, err := entry.Parse(`<p>{{.Domain}}</p>{{.Text}}`)
+	require.NoError(t, err)
+
+	feed := build(t, FeedBuilder{Entry: tmpl}, []structs.Bookmark{
+		{ID: 1, Time: 1739202544, URL: "https://example.com/post", Text: "<p>Article text.</p>"},
+	})
+	require.Len(t, feed.Items, 1)
+	assert.Equal(t, "<p>example.com</p><p>Article text.</p>", feed.Items[0].ContentHTML)
+	assert.Empty(t, feed.Items[0].Authors)
+}
+
+func TestFeedBuilder_BuildNewestFirst(t *testing.T) {
+	bookmarks := []structs.Bookmark{
+		{ID: 1, Time: 1739202544, Title: "First"},
+		{ID: 2, Time: 1739300000, Title: "Second"},
+		{ID: 3, Time: 1739202544, Title: "Third"},
+	}
+
+	feed := build(t, FeedBuilder{}, bookmarks)
+
+	var ids []string
+	for _, item := range feed.Items {
+		ids = append(ids, item.ID)
+	}
+	assert.Equal(t, []string{"2", "3", "1"}, ids)
+	assert.Equal(t, 1, bookmarks[0].ID, "bookmarks are not sorted in place")
+
+	// items are required, even when there are none
+	empty, err := FeedBuilder{}.Build(nil)
+	require.NoError(t, err)
+	assert.Contains(t, string(empty), `"items": []`)
+}
@@ -0,0 +1,86 @@
+package jsonl
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/chuhlomin/instapaper2rss/pkg/structs"
+)
+
+// Filter selects bookmarks by the time they were saved.
+// Zero values mean "no limit"; Since is inclusive and Until is exclusive.
+type Filter struct {
+	Since time.Time
+	Until time.Time
+}
+
+func (f Filter) Match(b structs.Bookmark) bool {
+	t := time.Unix(b.Time, 0)
+	if !f.Since.IsZero() && t.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && !t.Before(f.Until) {
+		return false
+	}
+	return true
+}
+
+type Writer interface {
+	WriteBookmark(bookmark *structs.Bookmark) error
+}
+
+// Export writes bookmarks matching the filter as JSON Lines,
+// one bookmark per line, and returns the number of lines written.
+func Export(w io.Writer, bookmarks []structs.Bookmark, filter Filter) (int, error) {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+
+	count := 0
+	for _, b := range bookmarks {
+		if !filter.Match(b) {
+			continue
+		}
+
+		if err := enc.Encode(b); err != nil {
+			return count, fmt.Errorf("error encoding bookmark %d: %w", b.ID, err)
+		}
+		count++
+	}
+
+	return count, nil
+}
+
+// Import reads JSON Lines produced by Export and writes bookmarks
+// matching the filter to storage. Bookmarks are keyed by ID,
+// so importing the same file twice is safe.
+func Import(r io.Reader, storage Writer, filter Filter) (int, error) {
+	dec := json.NewDecoder(r)
+
+	count := 0
+	for line := 1; ; line++ {
+		var b structs.Bookmark
+		err := dec.Decode(&b)
+		if errors.Is(err, io.EOF) {
+			return count, nil
+		}
+		if err != nil {
+			return count, fmt.Errorf("error decoding record %d: %w", line, err)
+		}
+
+		if b.ID == 0 {
+			return count, fmt.Errorf("record %d has no bookmark ID", line)
+		}
+
+		if !filter.Match(b) {
+			continue
+		}
+
+		if err := storage.WriteBookmark(&b); err != nil {
+			return count, fmt.Errorf("error writing bookmark %d: %w", b.ID, err)
+		}
+		count++
+	}
+}
@@ -0,0 +1,56 @@
+package jsonl
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/chuhlomin/instapaper2rss/pkg/structs"
+)
+
+type memoryStorage map[int]structs.Bookmark
+
+func (m memoryStorage) WriteBookmark(b *structs.Bookmark) error {
+	m[b.ID] = *b
+	return nil
+}
+
+func TestExportImport(t *testing.T) {
+	bookmarks := []structs.Bookmark{
+		{ID: 1, Time: 1735689600, Title: "January", URL: "https://example.com/1", Text: "<p>one</p>"},
+		{ID: 2, Time: 1738368000, Title: "February", URL: "https://example.com/2", Text: "<p>two</p>\n"},
+		{ID: 3, Time: 1740787200, Title: "March", URL: "https://example.com/3?a=1&b=2"},
+	}
+
+	var buf bytes.Buffer
+	count, err := Export(&buf, bookmarks, Filter{
+		Since: time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC),
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, count)
+	assert.Equal(t, 2, bytes.Count(buf.Bytes(), []byte("\n")))
+
+	storage := memoryStorage{}
+	for i := 0; i < 2; i++ {
+		count, err = Import(bytes.NewReader(buf.Bytes()), storage, Filter{})
+		assert.NoError(t, err)
+		assert.Equal(t, 2, count)
+	}
+	assert.Equal(t, memoryStorage{2: bookmarks[1], 3: bookmarks[2]}, storage)
+
+	count, err = Import(bytes.NewReader(buf.Bytes()), memoryStorage{}, Filter{
+		Until: time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC),
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, count)
+}
+
+func TestImportErrors(t *testing.T) {
+	_, err := Import(bytes.NewBufferString("{\"ID\":1}\n{not json}\n"), memoryStorage{}, Filter{})
+	assert.ErrorContains(t, err, "error decoding record 2")
+
+	_, err = Import(bytes.NewBufferString("{\"Title\":\"no id\"}\n"), memoryStorage{}, Filter{})
+	assert.EqualError(t, err, "record 1 has no bookmark ID")
+}
@@ -0,0 +1,410 @@
+package markdown
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// skipped are elements whose content is not part of the article.
+var skipped = map[atom.Atom]bool{
+	atom.Head:     true,
+	atom.Script:   true,
+	atom.Style:    true,
+	atom.Noscript: true,
+	atom.Template: true,
+	atom.Iframe:   true,
+	atom.Svg:      true,
+	atom.Math:     true,
+	atom.Button:   true,
+	atom.Select:   true,
+	atom.Textarea: true,
+}
+
+// blocks are elements rendered as separate Markdown blocks.
+var blocks = map[atom.Atom]bool{
+	atom.Address:    true,
+	atom.Article:    true,
+	atom.Aside:      true,
+	atom.Blockquote: true,
+	atom.Dd:         true,
+	atom.Details:    true,
+	atom.Div:        true,
+	atom.Dl:         true,
+	atom.Dt:         true,
+	atom.Figcaption: true,
+	atom.Figure:     true,
+	atom.Footer:     true,
+	atom.Form:       true,
+	atom.H1:         true,
+	atom.H2:         true,
+	atom.H3:         true,
+	atom.H4:         true,
+	atom.H5:         true,
+	atom.H6:         true,
+	atom.Header:     true,
+	atom.Hr:         true,
+	atom.Li:         true,
+	atom.Main:       true,
+	atom.Nav:        true,
+	atom.Ol:         true,
+	atom.P:          true,
+	atom.Pre:        true,
+	atom.Section:    true,
+	atom.Summary:    true,
+	atom.Table:      true,
+	atom.Ul:         true,
+}
+
+// listItem matches the first line of a rendered list.
+var listItem = regexp.MustCompile(`^(- |[0-9]+\. )`)
+
+var headingLevels = map[atom.Atom]int{
+	atom.H1: 1, atom.H2: 2, atom.H3: 3, atom.H4: 4, atom.H5: 5, atom.H6: 6,
+}
+
+// Convert turns article HTML into Markdown.
+// Elements without a Markdown equivalent are replaced with their text.
+func Convert(text string) (string, error) {
+	nodes, err := html.ParseFragment(strings.NewReader(text), &html.Node{
+		Type:     html.ElementNode,
+		Data:     "body",
+		DataAtom: atom.Body,
+	})
+	if err != nil {
+		return "", fmt.Errorf("error parsing HTML: %w", err)
+	}
+
+	root := &html.Node{Type: html.DocumentNode}
+	for _, n := range nodes {
+		root.AppendChild(n)
+	}
+
+	return strings.Join(renderBlocks(root), "\n\n"), nil
+}
+
+// renderBlocks renders the children of n as Markdown blocks,
+// collecting runs of inline content into paragraphs.
+func renderBlocks(n *html.Node) []string {
+	var (
+		result    []string
+		paragraph strings.Builder
+	)
+
+	flush := func() {
+		if p := strings.TrimSpace(collapseSpaces(paragraph.String())); p != "" {
+			result = append(result, p)
+		}
+		paragraph.Reset()
+	}
+
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.ElementNode && skipped[c.DataAtom] {
+			continue
+		}
+
+		if c.Type != html.ElementNode || !blocks[c.DataAtom] {
+			paragraph.WriteString(renderInline(c))
+			continue
+		}
+
+		flush()
+		if block := renderBlock(c); block != "" {
+			result = append(result, block)
+		}
+	}
+	flush()
+
+	return result
+}
+
+func renderBlock(n *html.Node) string {
+	if level, ok := headingLevels[n.DataAtom]; ok {
+		text := strings.TrimSpace(collapseSpaces(renderChildren(n)))
+		if text == "" {
+			return ""
+		}
+		return strings.Repeat("#", level) + " " + text
+	}
+
+	switch n.DataAtom {
+	case atom.Hr:
+		return "---"
+
+	case atom.Pre:
+		code := strings.Trim(textContent(n), "\n")
+		fence := "```"
+		for strings.Contains(code, fence) {
+			fence += "`"
+		}
+		return fence + "\n" + code + "\n" + fence
+
+	case atom.Blockquote:
+		return prefixLines(strings.Join(renderBlocks(n), "\n\n"), "> ", ">")
+
+	case atom.Ul, atom.Ol:
+		return renderList(n)
+
+	case atom.Table:
+		return renderTable(n)
+	}
+
+	return strings.Join(renderBlocks(n), "\n\n")
+}
+
+func renderList(n *html.Node) string {
+	start := 1
+	if v, err := strconv.Atoi(attr(n, "start")); err == nil {
+		start = v
+	}
+
+	var items []string
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type != html.ElementNode || c.DataAtom != atom.Li {
+			continue
+		}
+
+		marker := "- "
+		if n.DataAtom == atom.Ol {
+			marker = strconv.Itoa(start+len(items)) + ". "
+		}
+
+		// nested lists follow the item text without a blank line
+		var content strings.Builder
+		for i, block := range renderBlocks(c) {
+			if i > 0 {
+				if listItem.MatchString(block) {
+					content.WriteString("\n")
+				} else {
+					content.WriteString("\n\n")
+				}
+			}
+			content.WriteString(block)
+		}
+
+		items = append(items, marker+prefixLines(content.String(), strings.Repeat(" ", len(marker)), "")[len(marker):])
+	}
+
+	return strings.Join(items, "\n")
+}
+
+func renderTable(n *html.Node) string {
+	var rows [][]string
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type != html.ElementNode {
+				continue
+			}
+			if c.DataAtom != atom.Tr {
+				walk(c)
+				continue
+			}
+
+			var row []string
+			for cell := c.FirstChild; cell != nil; cell = cell.NextSibling {
+				if cell.Type == html.ElementNode && (cell.DataAtom == atom.Td || cell.DataAtom == atom.Th) {
+					// text is escaped, so "|" in cells doesn't end them
+					row = append(row, strings.TrimSpace(collapseSpaces(renderChildren(cell))))
+				}
+			}
+			rows = append(rows, row)
+		}
+	}
+	walk(n)
+
+	if len(rows) == 0 {
+		return ""
+	}
+
+	columns := 0
+	for _, row := range rows {
+		columns = max(columns, len(row))
+	}
+
+	lines := make([]string, 0, len(rows)+1)
+	for i, row := range rows {
+		for len(row) < columns {
+			row = append(row, "")
+		}
+		lines = append(lines, "| "+strings.Join(row, " | ")+" |")
+		if i == 0 {
+			lines = append(lines, "|"+strings.Repeat(" --- |", columns))
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func renderInline(n *html.Node) string {
+	switch n.Type {
+	case html.TextNode:
+		return escape(n.Data)
+	case html.ElementNode:
+	default:
+		return ""
+	}
+
+	if skipped[n.DataAtom] {
+		return ""
+	}
+
+	switch n.DataAtom {
+	case atom.Br:
+		return "\\\n"
+
+	case atom.Em, atom.I, atom.Cite:
+		return wrap(renderChildren(n), "*")
+
+	case atom.Strong, atom.B:
+		return wrap(renderChildren(n), "**")
+
+	case atom.Del, atom.S, atom.Strike:
+		return wrap(renderChildren(n), "~~")
+
+	case atom.Code, atom.Kbd, atom.Samp:
+		code := textContent(n)
+		fence := "`"
+		for strings.Contains(code, fence) {
+			fence += "`"
+		}
+		if strings.HasPrefix(code, "`") || strings.HasSuffix(code, "`") {
+			code = " " + code + " "
+		}
+		return fence + code + fence
+
+	case atom.A:
+		text := strings.TrimSpace(collapseSpaces(renderChildren(n)))
+		href := attr(n, "href")
+		if href == "" || strings.HasPrefix(href, "javascript:") {
+			return text
+		}
+		if text == "" {
+			text = escape(href)
+		}
+		return "[" + text + "](" + escapeURL(href) + ")"
+
+	case atom.Img:
+		src := attr(n, "src")
+		if src == "" {
+			return ""
+		}
+		return "![" + escape(attr(n, "alt")) + "](" + escapeURL(src) + ")"
+	}
+
+	return renderChildren(n)
+}
+
+func renderChildren(n *html.Node) string {
+	var sb strings.Builder
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.ElementNode && blocks[c.DataAtom] {
+			// block inside inline content, e.g. <a><div>…</div></a>
+			sb.WriteString(" " + strings.Join(renderBlocks(c), " ") + " ")
+			continue
+		}
+		sb.WriteString(renderInline(c))
+	}
+	return sb.String()
+}
+
+// wrap surrounds text with the emphasis marker,
+// keeping surrounding spaces outside of it.
+func wrap(text, marker string) string {
+	trimmed := strings.TrimSpace(collapseSpaces(text))
+	if trimmed == "" {
+		return text
+	}
+
+	leading := text[:len(text)-len(strings.TrimLeft(text, " \t\n"))]
+	trailing := text[len(strings.TrimRight(text, " \t\n")):]
+	return collapseSpaces(leading) + marker + trimmed + marker + collapseSpaces(trailing)
+}
+
+func textContent(n *html.Node) string {
+	if n.Type == html.TextNode {
+		return n.Data
+	}
+
+	var sb strings.Builder
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.ElementNode && c.DataAtom == atom.Br {
+			sb.WriteString("\n")
+			continue
+		}
+		sb.WriteString(textContent(c))
+	}
+	return sb.String()
+}
+
+// collapseSpaces replaces runs of whitespace with one space,
+// keeping hard line breaks.
+func collapseSpaces(s string) string {
+	var sb strings.Builder
+	space := false
+	for i, r := range s {
+		switch r {
+		case ' ', '\t', '\n', '\r', '\f':
+			if r == '\n' && i > 0 && s[i-1] == '\\' {
+				sb.WriteRune('\n')
+				space = false
+				continue
+			}
+			space = true
+			continue
+		}
+		if space {
+			sb.WriteByte(' ')
+			space = false
+		}
+		sb.WriteRune(r)
+	}
+	if space {
+		sb.WriteByte(' ')
+	}
+	return sb.String()
+}
+
+// escape backslash-escapes characters that Markdown would treat as markup.
+func escape(s string) string {
+	var sb strings.Builder
+	for _, r := range s {
+		switch r {
+		case '\\', '*', '_', '`', '[', ']', '<', '>', '#', '|', '~':
+			sb.WriteByte('\\')
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
+
+func escapeURL(s string) string {
+	r := strings.NewReplacer(" ", "%20", "(", "%28", ")", "%29")
+	return r.Replace(strings.TrimSpace(s))
+}
+
+// prefixLines adds prefix to every line of s, and emptyPrefix to empty lines.
+func prefixLines(s, prefix, emptyPrefix string) string {
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		if line == "" {
+			lines[i] = emptyPrefix
+			continue
+		}
+		lines[i] = prefix + line
+	}
+	return strings.Join(lines, "\n")
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
@@ -0,0 +1,129 @@
+package markdown
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/chuhlomin/instapaper2rss/pkg/structs"
+)
+
+func TestConvert(t *testing.T) {
+	input, err := os.ReadFile("testdata/article.html")
+	require.NoError(t, err)
+
+	expected, err := os.ReadFile("testdata/article.md")
+	require.NoError(t, err)
+
+	got, err := Convert(string(input))
+	require.NoError(t, err)
+	assert.Equal(t, strings.TrimSpace(string(expected)), got)
+}
+
+func TestConvert_Inline(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"empty", "", ""},
+		{"text", "plain text", "plain text"},
+		{"spaces", "<p>  a\n\tb  </p>", "a b"},
+		{"emphasis spaces", "<p>a<em> b </em>c</p>", "a *b* c"},
+		{"empty emphasis", "<p>a<strong> </strong>b</p>", "a b"},
+		{"code with backticks", "<p><code>a`b</code></p>", "``a`b``"},
+		{"link without text", `<a href="https://example.com/">  </a>`, "[https://example.com/](https://example.com/)"},
+		{"link without href", "<a>text</a>", "text"},
+		{"javascript link", `<a href="javascript:alert(1)">text</a>`, "text"},
+		{"image without src", `<img alt="x">`, ""},
+		{"fence in pre", "<pre>```\ncode\n```</pre>", "````\n```\ncode\n```\n````"},
+		{"nested quote", "<blockquote><blockquote>a</blockquote></blockquote>", "> > a"},
+		{"multi-paragraph item", "<ul><li><p>a</p><p>b</p></li></ul>", "- a\n\n  b"},
+		{"block in link", `<a href="/x"><div>a</div></a>`, "[a](/x)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Convert(tt.input)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, got)
+		})
+	}
+}
+
+func TestNote(t *testing.T) {
+	got, err := Note(structs.Bookmark{
+		ID:     123,
+		Time:   1739145600,
+		Title:  `Notes: "a" guide`,
+		URL:    "https://example.com/notes",
+		Text:   "<p>Text</p>",
+		Tags:   []string{"writing", "pkm"},
+		Folder: "unread",
+		Highlights: []structs.Highlight{
+			{ID: 1, Text: "First passage", Position: 0},
+			{ID: 2, Text: "Second\npassage", Position: 1},
+		},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, `---
+title: "Notes: \"a\" guide"
+url: "https://example.com/notes"
+saved: "2025-02-10T00:00:00Z"
+tags: ["writing","pkm"]
+folder: "unread"
+instapaper_id: 123
+---
+
+# Notes: "a" guide
+
+Text
+
+## Highlights
+
+> First passage
+
+> Second
+> passage
+
+`+Marker+"\n", got)
+}
+
+func TestNote_Empty(t *testing.T) {
+	got, err := Note(structs.Bookmark{ID: 1, URL: "https://example.com/"})
+	require.NoError(t, err)
+	assert.Contains(t, got, "tags: []\n")
+	assert.Contains(t, got, "\n# https://example.com/\n\n"+Marker)
+	assert.NotContains(t, got, "## Highlights")
+}
+
+func TestMerge(t *testing.T) {
+	note := "# Title\n\nNew text\n\n" + Marker + "\n"
+
+	got, ok := Merge(note, "")
+	assert.True(t, ok)
+	assert.Equal(t, note, got)
+
+	got, ok = Merge(note, "# Title\n\nOld text\n\n"+Marker+"\n\nMy notes\n")
+	assert.True(t, ok)
+	assert.Equal(t, "# Title\n\nNew text\n\n"+Marker+"\n\nMy notes\n", got)
+
+	_, ok = Merge(note, "# Title\n\nEdited without the marker\n")
+	assert.False(t, ok)
+}
+
+func TestID(t *testing.T) {
+	note, err := Note(structs.Bookmark{ID: 123, Title: "instapaper_id: 1"})
+	require.NoError(t, err)
+
+	id, ok := ID(note + "\nMy notes\n")
+	assert.True(t, ok)
+	assert.Equal(t, 123, id)
+
+	_, ok = ID("# No front matter\n\ninstapaper_id: 1\n")
+	assert.False(t, ok)
+}
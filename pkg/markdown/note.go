@@ -0,0 +1,113 @@
+package markdown
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/chuhlomin/instapaper2rss/pkg/structs"
+)
+
+// Marker separates the exported note from the user's own notes.
+// Everything from the marker on is kept when the note is exported again.
+const Marker = "<!-- notes: anything below this line is kept on export -->"
+
+// frontMatter is the YAML front matter of a note.
+// JSON values are valid YAML, so fields are written with encoding/json
+// to quote titles and tags safely.
+type frontMatter struct {
+	key   string
+	value any
+}
+
+// Note renders the bookmark as a Markdown note: YAML front matter,
+// the title, the article text, highlights as quotes and the notes marker.
+func Note(b structs.Bookmark) (string, error) {
+	body, err := Convert(b.Text)
+	if err != nil {
+		return "", err
+	}
+
+	tags := b.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+
+	fields := []frontMatter{
+		{"title", b.Title},
+		{"url", b.URL},
+		{"saved", time.Unix(b.Time, 0).UTC().Format(time.RFC3339)},
+		{"tags", tags},
+		{"folder", b.Folder},
+		{"instapaper_id", b.ID},
+	}
+
+	var sb strings.Builder
+	sb.WriteString("---\n")
+	for _, f := range fields {
+		value, err := json.Marshal(f.value)
+		if err != nil {
+			return "", fmt.Errorf("error encoding %s: %w", f.key, err)
+		}
+		fmt.Fprintf(&sb, "%s: %s\n", f.key, value)
+	}
+	sb.WriteString("---\n\n")
+
+	title := b.Title
+	if title == "" {
+		title = b.URL
+	}
+	fmt.Fprintf(&sb, "# %s\n\n", escape(title))
+
+	if body != "" {
+		sb.WriteString(body + "\n\n")
+	}
+
+	if len(b.Highlights) > 0 {
+		sb.WriteString("## Highlights\n\n")
+		for _, h := range b.Highlights {
+			sb.WriteString(prefixLines(strings.TrimSpace(escape(h.Text)), "> ", ">") + "\n\n")
+		}
+	}
+
+	sb.WriteString(Marker + "\n")
+	return sb.String(), nil
+}
+
+// Merge returns the note with the user's notes from existing,
+// everything from the marker on. It reports false when existing
+// has no marker, so it can't be updated without losing edits.
+func Merge(note, existing string) (string, bool) {
+	if existing == "" {
+		return note, true
+	}
+
+	i := strings.Index(existing, Marker)
+	if i < 0 {
+		return "", false
+	}
+
+	return strings.TrimSuffix(note, Marker+"\n") + existing[i:], true
+}
+
+// ID returns the instapaper_id from the front matter of the note.
+func ID(note string) (int, bool) {
+	front, ok := strings.CutPrefix(note, "---\n")
+	if !ok {
+		return 0, false
+	}
+	front, _, ok = strings.Cut(front, "\n---\n")
+	if !ok {
+		return 0, false
+	}
+
+	for _, line := range strings.Split(front, "\n") {
+		if value, ok := strings.CutPrefix(line, "instapaper_id: "); ok {
+			id, err := strconv.Atoi(value)
+			return id, err == nil
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,69 @@
+// Package precompress compresses files ahead of time for static hosts
+// that serve feed.xml.gz or feed.xml.br to clients accepting them.
+package precompress
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+)
+
+// Encoding compresses files into siblings with the extension Ext.
+type Encoding struct {
+	Ext       string
+	newWriter func(w io.Writer) (io.WriteCloser, error)
+}
+
+var (
+	Gzip = Encoding{
+		Ext: ".gz",
+		newWriter: func(w io.Writer) (io.WriteCloser, error) {
+			// the header has no name or modification time,
+			// so the same content is always compressed the same way
+			return gzip.NewWriterLevel(w, gzip.BestCompression)
+		},
+	}
+
+	Brotli = Encoding{
+		Ext: ".br",
+		newWriter: func(w io.Writer) (io.WriteCloser, error) {
+			return brotli.NewWriterLevel(w, brotli.BestCompression), nil
+		},
+	}
+)
+
+// Parse parses a comma-separated list of encodings, "gz" and "br".
+// An empty list has no encodings.
+func Parse(list string) ([]Encoding, error) {
+	var encodings []Encoding
+	for _, name := range strings.Split(list, ",") {
+		switch strings.TrimPrefix(strings.TrimSpace(name), ".") {
+		case "":
+		case "gz", "gzip":
+			encodings = append(encodings, Gzip)
+		case "br", "brotli":
+			encodings = append(encodings, Brotli)
+		default:
+			return nil, fmt.Errorf("unknown encoding %q, expected %q or %q", name, "gz", "br")
+		}
+	}
+	return encodings, nil
+}
+
+// Compress writes the compressed content of r to w.
+func (e Encoding) Compress(w io.Writer, r io.Reader) error {
+	cw, err := e.newWriter(w)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(cw, r); err != nil {
+		cw.Close()
+		return err
+	}
+
+	return cw.Close()
+}
@@ -0,0 +1,83 @@
+package precompress
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		list     string
+		expected []string
+		err      string
+	}{
+		{list: "", expected: nil},
+		{list: "gz", expected: []string{".gz"}},
+		{list: "gz, br", expected: []string{".gz", ".br"}},
+		{list: ".br,gzip", expected: []string{".br", ".gz"}},
+		{list: "zstd", err: `unknown encoding "zstd", expected "gz" or "br"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.list, func(t *testing.T) {
+			encodings, err := Parse(tt.list)
+			if tt.err != "" {
+				assert.EqualError(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+
+			var exts []string
+			for _, e := range encodings {
+				exts = append(exts, e.Ext)
+			}
+			assert.Equal(t, tt.expected, exts)
+		})
+	}
+}
+
+func TestEncoding_Compress(t *testing.T) {
+	feed := strings.Repeat("<entry>feed</entry>\n", 100)
+
+	tests := []struct {
+		encoding  Encoding
+		newReader func(r io.Reader) (io.Reader, error)
+	}{
+		{
+			encoding: Gzip,
+			newReader: func(r io.Reader) (io.Reader, error) {
+				return gzip.NewReader(r)
+			},
+		},
+		{
+			encoding: Brotli,
+			newReader: func(r io.Reader) (io.Reader, error) {
+				return brotli.NewReader(r), nil
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.encoding.Ext, func(t *testing.T) {
+			var first, second bytes.Buffer
+			require.NoError(t, tt.encoding.Compress(&first, strings.NewReader(feed)))
+			require.NoError(t, tt.encoding.Compress(&second, strings.NewReader(feed)))
+
+			assert.Less(t, first.Len(), len(feed))
+			assert.Equal(t, first.Bytes(), second.Bytes(), "compression is deterministic")
+
+			r, err := tt.newReader(&first)
+			require.NoError(t, err)
+			data, err := io.ReadAll(r)
+			require.NoError(t, err)
+			assert.Equal(t, feed, string(data))
+		})
+	}
+}
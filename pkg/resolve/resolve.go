@@ -0,0 +1,253 @@
+package resolve
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// urlAttrs are attributes holding a single URL.
+var urlAttrs = map[string]bool{
+	"action":     true,
+	"background": true,
+	"cite":       true,
+	"data":       true,
+	"href":       true,
+	"longdesc":   true,
+	"poster":     true,
+	"src":        true,
+}
+
+// lazyAttrs hold the real image URL of lazy-loaded images,
+// in order of preference.
+var lazyAttrs = []string{"data-src", "data-lazy-src", "data-original"}
+
+// URLs rewrites every URL in the HTML fragment to an absolute one,
+// resolved against base or the document's <base href>.
+// Images loaded lazily from data-src or only given a srcset
+// get a src attribute, so they display without scripts.
+func URLs(text, base string) (string, error) {
+	baseURL, err := url.Parse(base)
+	if err != nil {
+		return "", fmt.Errorf("error parsing base URL: %w", err)
+	}
+
+	// without scripting, <noscript> content is parsed as elements
+	// and its URLs are resolved too
+	nodes, err := html.ParseFragmentWithOptions(strings.NewReader(text), &html.Node{
+		Type:     html.ElementNode,
+		Data:     "body",
+		DataAtom: atom.Body,
+	}, html.ParseOptionEnableScripting(false))
+	if err != nil {
+		return "", fmt.Errorf("error parsing HTML: %w", err)
+	}
+
+	root := &html.Node{Type: html.DocumentNode}
+	for _, n := range nodes {
+		root.AppendChild(n)
+	}
+
+	if href, ok := findBase(root); ok {
+		if u, err := baseURL.Parse(href); err == nil {
+			baseURL = u
+		}
+	}
+
+	walk(root, func(n *html.Node) {
+		for i, attr := range n.Attr {
+			switch {
+			case urlAttrs[attr.Key] || slices.Contains(lazyAttrs, attr.Key):
+				n.Attr[i].Val = resolve(baseURL, attr.Val)
+			case attr.Key == "srcset" || attr.Key == "data-srcset":
+				n.Attr[i].Val = resolveSrcset(baseURL, attr.Val)
+			}
+		}
+
+		if n.DataAtom == atom.Img {
+			promoteSrc(n, baseURL)
+		}
+	})
+
+	var sb strings.Builder
+	for n := root.FirstChild; n != nil; n = n.NextSibling {
+		if err := html.Render(&sb, n); err != nil {
+			return "", fmt.Errorf("error rendering HTML: %w", err)
+		}
+	}
+
+	return sb.String(), nil
+}
+
+func walk(n *html.Node, fn func(*html.Node)) {
+	if n.Type == html.ElementNode {
+		fn(n)
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		walk(c, fn)
+	}
+}
+
+func findBase(root *html.Node) (string, bool) {
+	var href string
+	found := false
+	walk(root, func(n *html.Node) {
+		if found || n.DataAtom != atom.Base {
+			return
+		}
+		if v, ok := getAttr(n, "href"); ok {
+			href, found = v, true
+		}
+	})
+	return href, found
+}
+
+// resolve returns value resolved against base,
+// or value unchanged if it is empty or not a valid URL.
+func resolve(base *url.URL, value string) string {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return value
+	}
+
+	u, err := base.Parse(value)
+	if err != nil {
+		return value
+	}
+	return u.String()
+}
+
+type candidate struct {
+	url        string
+	descriptor string
+}
+
+// parseSrcset splits a srcset value into candidates, e.g. "a.jpg 1x, b.jpg 2x".
+// As in the HTML spec, a URL runs up to whitespace, so it may contain commas
+// like "w_400,h_300/a.jpg", and its descriptor runs up to the next comma.
+func parseSrcset(value string) []candidate {
+	var result []candidate
+	for {
+		value = strings.TrimLeft(value, " \t\n\f\r,")
+		if value == "" {
+			return result
+		}
+
+		end := strings.IndexAny(value, " \t\n\f\r")
+		if end < 0 {
+			end = len(value)
+		}
+		c := candidate{url: value[:end]}
+		value = value[end:]
+
+		// a URL ending with commas has no descriptor
+		if trimmed := strings.TrimRight(c.url, ","); trimmed != c.url {
+			c.url = trimmed
+		} else {
+			descriptor, rest := descriptorEnd(value)
+			c.descriptor = strings.Join(strings.Fields(descriptor), " ")
+			value = rest
+		}
+
+		result = append(result, c)
+	}
+}
+
+// descriptorEnd splits value at the comma ending the descriptor,
+// skipping commas in parentheses.
+func descriptorEnd(value string) (string, string) {
+	depth := 0
+	for i, r := range value {
+		switch r {
+		case '(':
+			depth++
+		case ')':
+			if depth > 0 {
+				depth--
+			}
+		case ',':
+			if depth == 0 {
+				return value[:i], value[i+1:]
+			}
+		}
+	}
+	return value, ""
+}
+
+func resolveSrcset(base *url.URL, value string) string {
+	candidates := parseSrcset(value)
+	parts := make([]string, len(candidates))
+	for i, c := range candidates {
+		parts[i] = resolve(base, c.url)
+		if c.descriptor != "" {
+			parts[i] += " " + c.descriptor
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// largest returns the candidate with the largest width or density descriptor.
+func largest(candidates []candidate) string {
+	best, bestSize := "", -1.0
+	for _, c := range candidates {
+		size := 1.0
+		if d := c.descriptor; len(d) > 1 {
+			if v, err := strconv.ParseFloat(d[:len(d)-1], 64); err == nil {
+				size = v
+			}
+		}
+		if size > bestSize {
+			best, bestSize = c.url, size
+		}
+	}
+	return best
+}
+
+// promoteSrc sets src of a lazy-loaded image from data-src,
+// or from srcset when the image has no real src.
+func promoteSrc(n *html.Node, base *url.URL) {
+	for _, key := range lazyAttrs {
+		if v, ok := getAttr(n, key); ok && strings.TrimSpace(v) != "" {
+			setAttr(n, "src", resolve(base, v))
+			return
+		}
+	}
+
+	src, _ := getAttr(n, "src")
+	if src != "" && !strings.HasPrefix(src, "data:") {
+		return
+	}
+
+	for _, key := range []string{"srcset", "data-srcset"} {
+		if v, ok := getAttr(n, key); ok {
+			if best := largest(parseSrcset(v)); best != "" {
+				setAttr(n, "src", resolve(base, best))
+				return
+			}
+		}
+	}
+}
+
+func getAttr(n *html.Node, key string) (string, bool) {
+	for _, attr := range n.Attr {
+		if attr.Namespace == "" && attr.Key == key {
+			return attr.Val, true
+		}
+	}
+	return "", false
+}
+
+func setAttr(n *html.Node, key, value string) {
+	for i, attr := range n.Attr {
+		if attr.Namespace == "" && attr.Key == key {
+			n.Attr[i].Val = value
+			return
+		}
+	}
+	n.Attr = append(n.Attr, html.Attribute{Key: key, Val: value})
+}
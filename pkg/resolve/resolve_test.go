@@ -0,0 +1,56 @@
+package resolve
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestURLs(t *testing.T) {
+	// fixtures are article HTML as Instapaper returns it,
+	// with the expected output in <name>.expected.html
+	for _, name := range []string{"blog", "lazy", "base"} {
+		t.Run(name, func(t *testing.T) {
+			input, err := os.ReadFile("testdata/" + name + ".html")
+			require.NoError(t, err)
+
+			expected, err := os.ReadFile("testdata/" + name + ".expected.html")
+			require.NoError(t, err)
+
+			got, err := URLs(string(input), "https://example.com/blog/2025/02/post/")
+			require.NoError(t, err)
+			assert.Equal(t, strings.TrimSpace(string(expected)), strings.TrimSpace(got))
+		})
+	}
+}
+
+func TestURLs_InvalidURLs(t *testing.T) {
+	got, err := URLs(`<a href="http://[::1">broken</a><img src="">`, "https://example.com/post")
+	require.NoError(t, err)
+	assert.Equal(t, `<a href="http://[::1">broken</a><img src=""/>`, got)
+
+	_, err = URLs("<p>text</p>", "%zz")
+	assert.ErrorContains(t, err, "error parsing base URL")
+}
+
+func TestLargest(t *testing.T) {
+	assert.Equal(t, "b.jpg", largest(parseSrcset("a.jpg 480w, b.jpg 1200w, c.jpg 800w")))
+	assert.Equal(t, "b.jpg", largest(parseSrcset("a.jpg, b.jpg 2x")))
+	assert.Equal(t, "", largest(nil))
+}
+
+func TestParseSrcset(t *testing.T) {
+	assert.Equal(t, []candidate{
+		{url: "w_400,h_300/a.jpg", descriptor: "400w"},
+		{url: "w_800,h_600/a.jpg", descriptor: "800w"},
+	}, parseSrcset("w_400,h_300/a.jpg 400w,w_800,h_600/a.jpg 800w"))
+
+	assert.Equal(t, []candidate{
+		{url: "a.jpg"},
+		{url: "b.jpg", descriptor: "2x"},
+		{url: "data:image/png;base64,AAA="},
+	}, parseSrcset(" a.jpg, b.jpg  2x ,data:image/png;base64,AAA="))
+}
@@ -0,0 +1,154 @@
+package rss
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/xml"
+	"io"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/chuhlomin/instapaper2rss/pkg/entry"
+	"github.com/chuhlomin/instapaper2rss/pkg/structs"
+	"github.com/chuhlomin/instapaper2rss/pkg/xmltext"
+)
+
+type RSS struct {
+	XMLName      xml.Name `xml:"rss"`
+	Version      string   `xml:"version,attr"`
+	XmlnsContent string   `xml:"xmlns:content,attr"`
+	XmlnsAtom    string   `xml:"xmlns:atom,attr"`
+	Channel      Channel  `xml:"channel"`
+}
+
+type Channel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	AtomLink      *AtomLink `xml:"atom:link"`
+	LastBuildDate string    `xml:"lastBuildDate"`
+	Item          []Item    `xml:"item"`
+}
+
+type AtomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+	Type string `xml:"type,attr"`
+}
+
+type Item struct {
+	Title       string   `xml:"title"`
+	Link        string   `xml:"link"`
+	Description string   `xml:"description,omitempty"`
+	Content     Content  `xml:"content:encoded"`
+	GUID        GUID     `xml:"guid"`
+	PubDate     string   `xml:"pubDate"`
+	Category    []string `xml:"category"`
+}
+
+type Content struct {
+	Body string `xml:",cdata"`
+}
+
+type GUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+// FeedBuilder builds an RSS 2.0 feed.
+// Empty fields fall back to defaults; without SelfURL
+// the channel has no atom:link, without Entry
+// the item content is the article text.
+type FeedBuilder struct {
+	Title       string
+	Link        string
+	Description string
+	SelfURL     string
+	Entry       *entry.Template
+}
+
+// Build returns the feed with items newest first. The last build date
+// is the newest item's, so the same bookmarks always produce the same document.
+func (fb FeedBuilder) Build(bookmarks []structs.Bookmark) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := fb.BuildTo(&buf, bookmarks); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// BuildTo writes the feed to w as it is encoded.
+func (fb FeedBuilder) BuildTo(w io.Writer, bookmarks []structs.Bookmark) error {
+	bookmarks = sortNewestFirst(bookmarks)
+
+	lastBuild := time.Unix(0, 0).UTC()
+	if len(bookmarks) > 0 {
+		lastBuild = time.Unix(bookmarks[0].Time, 0)
+	}
+
+	feed := RSS{
+		Version:      "2.0",
+		XmlnsContent: "http://purl.org/rss/1.0/modules/content/",
+		XmlnsAtom:    "http://www.w3.org/2005/Atom",
+		Channel: Channel{
+			Title:         orDefault(xmltext.Clean(fb.Title), "Instapaper"),
+			Link:          orDefault(xmltext.Clean(fb.Link), "https://www.instapaper.com/u"),
+			Description:   orDefault(xmltext.Clean(fb.Description), "Instapaper bookmarks"),
+			LastBuildDate: lastBuild.Format(time.RFC1123Z),
+			Item:          make([]Item, len(bookmarks)),
+		},
+	}
+
+	if fb.SelfURL != "" {
+		feed.Channel.AtomLink = &AtomLink{
+			Href: xmltext.Clean(fb.SelfURL),
+			Rel:  "self",
+			Type: "application/rss+xml",
+		}
+	}
+
+	for i, b := range bookmarks {
+		b = xmltext.Bookmark(b)
+		content, err := fb.Entry.Render(b)
+		if err != nil {
+			return err
+		}
+
+		feed.Channel.Item[i] = Item{
+			Title:       b.FeedTitle(),
+			Link:        b.URL,
+			Description: b.FeedSummary(),
+			Content:     Content{Body: xmltext.Clean(content)},
+			GUID: GUID{
+				IsPermaLink: false,
+				Value:       strconv.Itoa(b.ID),
+			},
+			PubDate:  time.Unix(b.Time, 0).Format(time.RFC1123Z),
+			Category: b.Tags,
+		}
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	return enc.Encode(feed)
+}
+
+func sortNewestFirst(bookmarks []structs.Bookmark) []structs.Bookmark {
+	sorted := slices.Clone(bookmarks)
+	slices.SortStableFunc(sorted, func(a, b structs.Bookmark) int {
+		return cmp.Or(cmp.Compare(b.Time, a.Time), cmp.Compare(b.ID, a.ID))
+	})
+	return sorted
+}
+
+func orDefault(value, defaultValue string) string {
+	if value == "" {
+		return defaultValue
+	}
+	return value
+}
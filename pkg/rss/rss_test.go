@@ -0,0 +1,99 @@
+package rss
+
+import (
+	"encoding/xml"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/chuhlomin/instapaper2rss/pkg/entry"
+	"github.com/chuhlomin/instapaper2rss/pkg/structs"
+	"github.com/chuhlomin/instapaper2rss/pkg/validate"
+)
+
+func TestFeedBuilder_Build(t *testing.T) {
+	b, err := FeedBuilder{SelfURL: "https://example.com/feed.xml"}.Build([]structs.Bookmark{
+		{
+			ID:          1,
+			Time:        1739202544,
+			Title:       "Test Bookmark",
+			URL:         "https://example.com/1",
+			Text:        "<p>Test content ]]> with CDATA end</p>",
+			Description: "Short description",
+			Tags:        []string{"go", "rss"},
+			Starred:     true,
+		},
+	})
+	require.NoError(t, err)
+
+	s := string(b)
+	assert.Contains(t, s, `<rss version="2.0" xmlns:content="http://purl.org/rss/1.0/modules/content/" xmlns:atom="http://www.w3.org/2005/Atom">`)
+	assert.Contains(t, s, `<atom:link href="https://example.com/feed.xml" rel="self" type="application/rss+xml"></atom:link>`)
+	assert.Contains(t, s, `<guid isPermaLink="false">1</guid>`)
+	assert.Contains(t, s, `<pubDate>`+time.Unix(1739202544, 0).Format(time.RFC1123Z)+`</pubDate>`)
+	assert.Contains(t, s, `<title>★ Test Bookmark</title>`)
+	assert.Contains(t, s, `<description>Short description</description>`)
+	assert.Contains(t, s, `<category>go</category>`)
+
+	var feed struct {
+		Items []struct {
+			Content string `xml:"http://purl.org/rss/1.0/modules/content/ encoded"`
+		} `xml:"channel>item"`
+	}
+	require.NoError(t, xml.Unmarshal(b, &feed))
+	require.Len(t, feed.Items, 1)
+	assert.Equal(t, "<p>Test content ]]> with CDATA end</p>", feed.Items[0].Content)
+}
+
+func TestFeedBuilder_BuildEntryTemplate(t *testing.T) {
+	tmpl, err := entry.Parse(`<p>{{.Domain}}</p>{{.Text}}`)
+	require.NoError(t, err)
+
+	b, err := FeedBuilder{Entry: tmpl}.Build([]structs.Bookmark{
+		{ID: 1, Time: 1739202544, URL: "https://example.com/post", Text: "<p>Article text.</p>"},
+	})
+	require.NoError(t, err)
+	assert.Contains(t, string(b), `<content:encoded><![CDATA[<p>example.com</p><p>Article text.</p>]]></content:encoded>`)
+}
+
+func TestFeedBuilder_BuildDeterministic(t *testing.T) {
+	bookmarks := []structs.Bookmark{
+		{ID: 1, Time: 1739202544, Title: "First", URL: "https://example.com/1"},
+		{ID: 2, Time: 1739300000, Title: "Second", URL: "https://example.com/2"},
+		{ID: 3, Time: 1739202544, Title: "Third", URL: "https://example.com/3"},
+	}
+
+	first, err := FeedBuilder{}.Build(bookmarks)
+	require.NoError(t, err)
+
+	second, err := FeedBuilder{}.Build([]structs.Bookmark{bookmarks[2], bookmarks[1], bookmarks[0]})
+	require.NoError(t, err)
+	assert.Equal(t, string(first), string(second))
+
+	empty, err := FeedBuilder{}.Build(nil)
+	require.NoError(t, err)
+	require.NoError(t, validate.Feed(empty))
+}
+
+func TestFeedBuilder_BuildInvalidCharacters(t *testing.T) {
+	b, err := FeedBuilder{}.Build([]structs.Bookmark{
+		{
+			ID:    1,
+			Time:  1739202544,
+			Title: "Vertical\x0btab",
+			URL:   "https://example.com/\x1f",
+			Text:  "<p>caf\xe9\x08 ]]> \xef\xbf\xbf</p>",
+			Tags:  []string{"go\x00"},
+		},
+	})
+	require.NoError(t, err)
+	require.NoError(t, validate.Feed(b))
+
+	s := string(b)
+	assert.Contains(t, s, `<title>Verticaltab</title>`)
+	assert.Contains(t, s, `<link>https://example.com/</link>`)
+	assert.Contains(t, s, `<category>go</category>`)
+	assert.Contains(t, s, "caf�")
+}
@@ -0,0 +1,302 @@
+package sanitize
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// Policy is an allowlist of elements and their attributes.
+// Elements that are not allowed are unwrapped, keeping their content,
+// except for the ones that are dropped with their content, such as scripts.
+type Policy struct {
+	// Elements maps allowed element names to their allowed attributes.
+	Elements map[string][]string
+	// Schemes lists URL schemes allowed in href and src attributes;
+	// relative URLs are always allowed.
+	Schemes []string
+}
+
+// dropped are elements removed together with their content.
+var dropped = map[string]bool{
+	"applet":   true,
+	"base":     true,
+	"button":   true,
+	"canvas":   true,
+	"embed":    true,
+	"frame":    true,
+	"frameset": true,
+	"head":     true,
+	"iframe":   true,
+	"input":    true,
+	"link":     true,
+	"math":     true,
+	"meta":     true,
+	"noscript": true,
+	"object":   true,
+	"script":   true,
+	"select":   true,
+	"style":    true,
+	"svg":      true,
+	"template": true,
+	"textarea": true,
+	"title":    true,
+}
+
+// urlAttrs are attributes holding URLs.
+var urlAttrs = map[string]bool{
+	"cite": true,
+	"href": true,
+	"src":  true,
+}
+
+// Strict allows basic text formatting and links only.
+func Strict() Policy {
+	p := Policy{
+		Elements: map[string][]string{},
+		Schemes:  []string{"http", "https", "mailto"},
+	}
+
+	for _, name := range []string{
+		"p", "br", "hr", "h1", "h2", "h3", "h4", "h5", "h6",
+		"blockquote", "pre", "code", "em", "strong", "b", "i", "u", "s",
+		"sub", "sup", "small", "mark", "del", "ins", "cite",
+		"ul", "ol", "li", "dl", "dt", "dd",
+	} {
+		p.Elements[name] = nil
+	}
+	p.Allow("a", "href", "title")
+	p.Allow("abbr", "title")
+	p.Allow("q", "cite")
+
+	return p
+}
+
+// ReaderFriendly extends Strict with images, figures, tables
+// and layout elements commonly found in articles.
+func ReaderFriendly() Policy {
+	p := Strict()
+
+	for _, name := range []string{
+		"div", "span", "section", "article", "header", "footer", "aside",
+		"figure", "figcaption", "details", "summary",
+		"table", "caption", "thead", "tbody", "tfoot", "tr",
+	} {
+		p.Elements[name] = nil
+	}
+	p.Allow("img", "src", "alt", "title", "width", "height")
+	p.Allow("th", "colspan", "rowspan", "scope")
+	p.Allow("td", "colspan", "rowspan")
+	p.Allow("ol", "start", "reversed")
+	p.Allow("time", "datetime")
+
+	return p
+}
+
+// Named returns the preset policy by name: "strict" or "reader-friendly".
+func Named(name string) (Policy, error) {
+	switch name {
+	case "strict":
+		return Strict(), nil
+	case "reader-friendly":
+		return ReaderFriendly(), nil
+	}
+	return Policy{}, fmt.Errorf("unknown sanitize policy %q", name)
+}
+
+// Allow adds the element and its attributes to the allowlist.
+func (p Policy) Allow(element string, attrs ...string) {
+	element = strings.ToLower(element)
+	for _, attr := range attrs {
+		p.Elements[element] = append(p.Elements[element], strings.ToLower(attr))
+	}
+	if _, ok := p.Elements[element]; !ok {
+		p.Elements[element] = nil
+	}
+}
+
+// Report counts what Sanitize removed.
+type Report struct {
+	Elements       map[string]int // removed or unwrapped elements by name
+	Attributes     map[string]int // removed attributes by name
+	TrackingPixels int            // images of 1×1 pixel or smaller
+}
+
+// Empty reports whether nothing was removed.
+func (r Report) Empty() bool {
+	return len(r.Elements) == 0 && len(r.Attributes) == 0 && r.TrackingPixels == 0
+}
+
+// String lists removed elements and attributes, e.g.
+// "elements: iframe=1 script=2; attributes: onclick=1; tracking pixels: 1".
+func (r Report) String() string {
+	var parts []string
+	if len(r.Elements) > 0 {
+		parts = append(parts, "elements: "+formatCounts(r.Elements))
+	}
+	if len(r.Attributes) > 0 {
+		parts = append(parts, "attributes: "+formatCounts(r.Attributes))
+	}
+	if r.TrackingPixels > 0 {
+		parts = append(parts, "tracking pixels: "+strconv.Itoa(r.TrackingPixels))
+	}
+	return strings.Join(parts, "; ")
+}
+
+func formatCounts(counts map[string]int) string {
+	names := make([]string, 0, len(counts))
+	for name := range counts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for i, name := range names {
+		names[i] = name + "=" + strconv.Itoa(counts[name])
+	}
+	return strings.Join(names, " ")
+}
+
+// Sanitize removes everything from the HTML fragment that the policy
+// does not allow, along with comments and tracking pixels.
+// The result is balanced markup with self-closing void elements.
+func (p Policy) Sanitize(text string) (string, Report, error) {
+	report := Report{
+		Elements:   map[string]int{},
+		Attributes: map[string]int{},
+	}
+
+	nodes, err := html.ParseFragment(strings.NewReader(text), &html.Node{
+		Type:     html.ElementNode,
+		Data:     "body",
+		DataAtom: atom.Body,
+	})
+	if err != nil {
+		return "", report, fmt.Errorf("error parsing HTML: %w", err)
+	}
+
+	root := &html.Node{Type: html.DocumentNode}
+	for _, n := range nodes {
+		root.AppendChild(n)
+	}
+
+	p.clean(root, &report)
+
+	var sb strings.Builder
+	for n := root.FirstChild; n != nil; n = n.NextSibling {
+		if err := html.Render(&sb, n); err != nil {
+			return "", report, fmt.Errorf("error rendering HTML: %w", err)
+		}
+	}
+
+	if len(report.Elements) == 0 {
+		report.Elements = nil
+	}
+	if len(report.Attributes) == 0 {
+		report.Attributes = nil
+	}
+
+	return strings.TrimSpace(sb.String()), report, nil
+}
+
+func (p Policy) clean(parent *html.Node, report *Report) {
+	for n := parent.FirstChild; n != nil; {
+		next := n.NextSibling
+
+		switch n.Type {
+		case html.TextNode:
+		case html.ElementNode:
+			attrs, allowed := p.Elements[n.Data]
+
+			switch {
+			case dropped[n.Data] || n.Namespace != "":
+				report.Elements[n.Data]++
+				parent.RemoveChild(n)
+
+			case !allowed:
+				report.Elements[n.Data]++
+				p.clean(n, report)
+				for c := n.FirstChild; c != nil; c = n.FirstChild {
+					n.RemoveChild(c)
+					parent.InsertBefore(c, n)
+				}
+				parent.RemoveChild(n)
+
+			case n.Data == "img" && isTrackingPixel(n):
+				report.TrackingPixels++
+				parent.RemoveChild(n)
+
+			default:
+				n.Attr = p.cleanAttrs(n.Attr, attrs, report)
+				if n.Data == "img" && !hasAttr(n, "src") {
+					report.Elements[n.Data]++
+					parent.RemoveChild(n)
+					break
+				}
+				p.clean(n, report)
+			}
+
+		default:
+			// comments, doctypes
+			parent.RemoveChild(n)
+		}
+
+		n = next
+	}
+}
+
+func (p Policy) cleanAttrs(attrs []html.Attribute, allowed []string, report *Report) []html.Attribute {
+	result := attrs[:0]
+	for _, attr := range attrs {
+		if attr.Namespace != "" || !slices.Contains(allowed, attr.Key) {
+			report.Attributes[attr.Key]++
+			continue
+		}
+		if urlAttrs[attr.Key] && !p.allowedURL(attr.Val) {
+			report.Attributes[attr.Key]++
+			continue
+		}
+		result = append(result, attr)
+	}
+	return result
+}
+
+func (p Policy) allowedURL(value string) bool {
+	u, err := url.Parse(strings.TrimSpace(value))
+	if err != nil {
+		return false
+	}
+	return u.Scheme == "" || slices.Contains(p.Schemes, u.Scheme)
+}
+
+// isTrackingPixel reports whether the image is declared 1×1 or smaller.
+func isTrackingPixel(n *html.Node) bool {
+	width, height := -1, -1
+	for _, attr := range n.Attr {
+		v, err := strconv.Atoi(strings.TrimSuffix(strings.TrimSpace(attr.Val), "px"))
+		if err != nil {
+			continue
+		}
+		switch attr.Key {
+		case "width":
+			width = v
+		case "height":
+			height = v
+		}
+	}
+	return width >= 0 && width <= 1 && height >= 0 && height <= 1
+}
+
+func hasAttr(n *html.Node, key string) bool {
+	for _, attr := range n.Attr {
+		if attr.Key == key {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,112 @@
+package sanitize
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPolicy_Sanitize(t *testing.T) {
+	input, err := os.ReadFile("testdata/article.html")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name           string
+		policy         Policy
+		expectedHTML   string
+		expectedReport Report
+	}{
+		{
+			name:   "reader-friendly",
+			policy: ReaderFriendly(),
+			expectedHTML: `<h1>Heading</h1>` + "\n" +
+				`<p>First <a>bad link</a> and <a href="https://example.com/">good link</a>.</p>` + "\n\n" +
+				`<figure><img src="https://example.com/photo.jpg" alt="Photo"/><figcaption>Caption</figcaption></figure>` + "\n\n" +
+				`Unwrapped <b>bold</b>` + "\n" +
+				`<table><tbody><tr><td colspan="2">Cell</td></tr></tbody></table>` + "\n" +
+				`Form text`,
+			expectedReport: Report{
+				Elements: map[string]int{
+					"button": 1, "font": 1, "form": 1, "iframe": 1, "input": 1,
+					"script": 1, "style": 1, "svg": 1, "title": 1,
+				},
+				Attributes: map[string]int{
+					"bgcolor": 1, "class": 1, "href": 1, "loading": 1,
+					"onclick": 1, "onmouseover": 1, "style": 1, "target": 1,
+				},
+				TrackingPixels: 1,
+			},
+		},
+		{
+			name:   "strict",
+			policy: Strict(),
+			expectedHTML: `<h1>Heading</h1>` + "\n" +
+				`<p>First <a>bad link</a> and <a href="https://example.com/">good link</a>.</p>` + "\n\n" +
+				`Caption` + "\n\n" +
+				`Unwrapped <b>bold</b>` + "\n" +
+				`Cell` + "\n" +
+				`Form text`,
+			expectedReport: Report{
+				Elements: map[string]int{
+					"button": 1, "figcaption": 1, "figure": 1, "font": 1, "form": 1,
+					"iframe": 1, "img": 2, "input": 1, "script": 1, "style": 1,
+					"svg": 1, "table": 1, "tbody": 1, "td": 1, "title": 1, "tr": 1,
+				},
+				Attributes: map[string]int{
+					"class": 1, "href": 1, "onclick": 1, "onmouseover": 1,
+					"style": 1, "target": 1,
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, report, err := tt.policy.Sanitize(string(input))
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedHTML, got)
+			assert.Equal(t, tt.expectedReport, report)
+		})
+	}
+}
+
+func TestPolicy_SanitizeClean(t *testing.T) {
+	input := `<p>Plain <em>text</em> with a <a href="/relative">link</a>.</p>`
+
+	got, report, err := ReaderFriendly().Sanitize(input)
+	require.NoError(t, err)
+	assert.Equal(t, input, got)
+	assert.True(t, report.Empty())
+	assert.Equal(t, "", report.String())
+}
+
+func TestPolicy_Allow(t *testing.T) {
+	p := Strict()
+	p.Allow("video", "src", "controls")
+
+	got, _, err := p.Sanitize(`<video src="https://example.com/v.mp4" controls autoplay></video>`)
+	require.NoError(t, err)
+	assert.Equal(t, `<video src="https://example.com/v.mp4" controls=""></video>`, got)
+}
+
+func TestReport_String(t *testing.T) {
+	r := Report{
+		Elements:       map[string]int{"script": 2, "iframe": 1},
+		Attributes:     map[string]int{"onclick": 1},
+		TrackingPixels: 1,
+	}
+	assert.Equal(t, "elements: iframe=1 script=2; attributes: onclick=1; tracking pixels: 1", r.String())
+}
+
+func TestNamed(t *testing.T) {
+	_, err := Named("strict")
+	assert.NoError(t, err)
+
+	_, err = Named("reader-friendly")
+	assert.NoError(t, err)
+
+	_, err = Named("paranoid")
+	assert.EqualError(t, err, `unknown sanitize policy "paranoid"`)
+}
@@ -0,0 +1,390 @@
+package site
+
+import (
+	"bytes"
+	"cmp"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/chuhlomin/instapaper2rss/pkg/sanitize"
+	"github.com/chuhlomin/instapaper2rss/pkg/slug"
+	"github.com/chuhlomin/instapaper2rss/pkg/structs"
+)
+
+//go:embed templates
+var templatesFS embed.FS
+
+const (
+	defaultTitle    = "Instapaper"
+	defaultPageSize = 50
+)
+
+// Builder renders a static reading site: paginated index pages
+// grouped by month, a page per tag and a reader-view page per bookmark.
+// Templates embedded in the package can be overridden by files
+// with the same names in TemplatesDir.
+type Builder struct {
+	Title        string
+	PageSize     int
+	TemplatesDir string
+}
+
+type item struct {
+	Title    string
+	URL      string
+	Domain   string
+	Page     string
+	Date     string
+	DateTime string
+	Excerpt  string
+	Tags     []tagLink
+}
+
+type tagLink struct {
+	Name  string
+	URL   string
+	Count int
+}
+
+type monthGroup struct {
+	Label string
+	Items []item
+}
+
+type page struct {
+	Site  string
+	Title string
+	Root  string // relative path to the site root, "" or "../"
+	Lang  string // language of the page, "en" unless detected
+}
+
+type indexPage struct {
+	page
+	AllTags    []tagLink
+	Months     []monthGroup
+	Page       int
+	Pages      int
+	Prev, Next string
+}
+
+type tagPage struct {
+	page
+	Tag    string
+	Months []monthGroup
+}
+
+type articlePage struct {
+	page
+	Item item
+	Body template.HTML
+}
+
+// Build returns the first index page. It only makes Builder
+// a feed builder: the site is written with BuildFiles and Prune.
+func (sb Builder) Build(bookmarks []structs.Bookmark) ([]byte, error) {
+	files, err := sb.BuildFiles("", bookmarks)
+	if err != nil {
+		return nil, err
+	}
+	return files[0].Data, nil
+}
+
+// BuildFiles renders the site into dir: index.html, page-N.html,
+// tags/<tag>.html, articles/<id>.html and style.css.
+func (sb Builder) BuildFiles(dir string, bookmarks []structs.Bookmark) ([]structs.File, error) {
+	tmpl, css, err := sb.templates()
+	if err != nil {
+		return nil, err
+	}
+
+	site := sb.Title
+	if site == "" {
+		site = defaultTitle
+	}
+	pageSize := sb.PageSize
+	if pageSize <= 0 {
+		pageSize = defaultPageSize
+	}
+
+	sorted := make([]structs.Bookmark, len(bookmarks))
+	copy(sorted, bookmarks)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].Time != sorted[j].Time {
+			return sorted[i].Time > sorted[j].Time
+		}
+		return sorted[i].ID > sorted[j].ID
+	})
+
+	tags, tagSlugs := collectTags(sorted)
+
+	var files []structs.File
+	render := func(name, tmplName string, data any) error {
+		var buf bytes.Buffer
+		if err := tmpl.ExecuteTemplate(&buf, tmplName, data); err != nil {
+			return fmt.Errorf("error rendering %s: %w", name, err)
+		}
+		files = append(files, structs.File{Path: filepath.Join(dir, name), Data: buf.Bytes()})
+		return nil
+	}
+
+	pages := max(1, (len(sorted)+pageSize-1)/pageSize)
+	for i := 0; i < pages; i++ {
+		chunk := sorted[min(i*pageSize, len(sorted)):min((i+1)*pageSize, len(sorted))]
+
+		data := indexPage{
+			page:    page{Site: site, Title: site, Lang: "en"},
+			AllTags: tagLinks(tags, tagSlugs, ""),
+			Months:  groupByMonth(chunk, tagSlugs, ""),
+			Page:    i + 1,
+			Pages:   pages,
+		}
+		if i > 0 {
+			data.Title = fmt.Sprintf("%s, page %d", site, i+1)
+			data.Prev = indexName(i - 1)
+		}
+		if i < pages-1 {
+			data.Next = indexName(i + 1)
+		}
+
+		if err := render(indexName(i), "index.html", data); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, t := range tags {
+		var tagged []structs.Bookmark
+		for _, b := range sorted {
+			if hasTag(b, t.Name) {
+				tagged = append(tagged, b)
+			}
+		}
+
+		data := tagPage{
+			page:   page{Site: site, Title: t.Name + " – " + site, Root: "../", Lang: "en"},
+			Tag:    t.Name,
+			Months: groupByMonth(tagged, tagSlugs, "../"),
+		}
+		if err := render(tagURL(t.Name, tagSlugs, ""), "tag.html", data); err != nil {
+			return nil, err
+		}
+	}
+
+	policy := sanitize.ReaderFriendly()
+	for _, b := range sorted {
+		body, _, err := policy.Sanitize(b.Text)
+		if err != nil {
+			return nil, fmt.Errorf("error sanitizing bookmark %d: %w", b.ID, err)
+		}
+
+		it := newItem(b, tagSlugs, "../")
+		data := articlePage{
+			page: page{Site: site, Title: it.Title, Root: "../", Lang: cmp.Or(b.Language, "en")},
+			Item: it,
+			Body: template.HTML(body), // #nosec G203 -- sanitized above
+		}
+		if err := render(articleName(b), "article.html", data); err != nil {
+			return nil, err
+		}
+	}
+
+	files = append(files, structs.File{Path: filepath.Join(dir, "style.css"), Data: css})
+
+	return files, nil
+}
+
+// templates parses the embedded templates, then the overrides.
+func (sb Builder) templates() (*template.Template, []byte, error) {
+	tmpl, err := template.ParseFS(templatesFS, "templates/*.html")
+	if err != nil {
+		return nil, nil, fmt.Errorf("error parsing templates: %w", err)
+	}
+
+	css, err := fs.ReadFile(templatesFS, "templates/style.css")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if sb.TemplatesDir == "" {
+		return tmpl, css, nil
+	}
+
+	overrides, err := filepath.Glob(filepath.Join(sb.TemplatesDir, "*.html"))
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(overrides) > 0 {
+		if tmpl, err = tmpl.ParseFiles(overrides...); err != nil {
+			return nil, nil, fmt.Errorf("error parsing templates from %s: %w", sb.TemplatesDir, err)
+		}
+	}
+
+	custom, err := os.ReadFile(filepath.Join(sb.TemplatesDir, "style.css"))
+	switch {
+	case err == nil:
+		css = custom
+	case !errors.Is(err, os.ErrNotExist):
+		return nil, nil, err
+	}
+
+	return tmpl, css, nil
+}
+
+func indexName(i int) string {
+	if i == 0 {
+		return "index.html"
+	}
+	return "page-" + strconv.Itoa(i+1) + ".html"
+}
+
+func articleName(b structs.Bookmark) string {
+	return "articles/" + strconv.Itoa(b.ID) + ".html"
+}
+
+// collectTags returns tags sorted by name with their bookmark counts,
+// and file name slugs keyed by lowercase tag name.
+func collectTags(bookmarks []structs.Bookmark) ([]tagLink, map[string]string) {
+	counts := map[string]*tagLink{}
+	for _, b := range bookmarks {
+		for _, tag := range b.Tags {
+			key := strings.ToLower(tag)
+			if counts[key] == nil {
+				counts[key] = &tagLink{Name: tag}
+			}
+			counts[key].Count++
+		}
+	}
+
+	tags := make([]tagLink, 0, len(counts))
+	for _, t := range counts {
+		tags = append(tags, *t)
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		return strings.ToLower(tags[i].Name) < strings.ToLower(tags[j].Name)
+	})
+
+	slugs := make(map[string]string, len(tags))
+	for _, t := range tags {
+		key := strings.ToLower(t.Name)
+		slugs[key] = tagSlug(key)
+	}
+
+	return tags, slugs
+}
+
+// tagSlug returns the file name of the lowercase tag. Tags that are
+// not slugs already, such as "Long reads" or "C++", get a hash of the name
+// appended, so they can't collide with other tags and keep their names
+// whichever tags are added later.
+func tagSlug(tag string) string {
+	s := slug.Make(tag)
+	if s == tag {
+		return s
+	}
+
+	sum := sha256.Sum256([]byte(tag))
+	return strings.TrimPrefix(s+"-", "-") + hex.EncodeToString(sum[:4])
+}
+
+// pagePatterns match the files BuildFiles writes into the site directory,
+// other than style.css, with their precompressed copies.
+var pagePatterns = []string{"index.html", "page-*.html", "tags/*.html", "articles/*.html"}
+
+// Prune removes pages from dir that are not in keep,
+// such as articles of deleted bookmarks and tags no longer used,
+// and returns their paths. Other files in dir are left alone.
+func (sb Builder) Prune(dir string, keep []string) ([]string, error) {
+	kept := make(map[string]bool, len(keep))
+	for _, path := range keep {
+		kept[filepath.Clean(path)] = true
+	}
+
+	var removed []string
+	for _, pattern := range pagePatterns {
+		for _, ext := range []string{"", ".gz", ".br"} {
+			matches, err := filepath.Glob(filepath.Join(dir, pattern+ext))
+			if err != nil {
+				return removed, err
+			}
+
+			for _, path := range matches {
+				if kept[filepath.Clean(path)] {
+					continue
+				}
+				if err := os.Remove(path); err != nil {
+					return removed, fmt.Errorf("error removing %s: %w", path, err)
+				}
+				removed = append(removed, path)
+			}
+		}
+	}
+
+	return removed, nil
+}
+
+func tagLinks(tags []tagLink, tagSlugs map[string]string, root string) []tagLink {
+	result := make([]tagLink, len(tags))
+	for i, t := range tags {
+		result[i] = tagLink{Name: t.Name, Count: t.Count, URL: tagURL(t.Name, tagSlugs, root)}
+	}
+	return result
+}
+
+func groupByMonth(sorted []structs.Bookmark, tagSlugs map[string]string, root string) []monthGroup {
+	var months []monthGroup
+	for _, b := range sorted {
+		label := time.Unix(b.Time, 0).UTC().Format("January 2006")
+		if len(months) == 0 || months[len(months)-1].Label != label {
+			months = append(months, monthGroup{Label: label})
+		}
+		months[len(months)-1].Items = append(months[len(months)-1].Items, newItem(b, tagSlugs, root))
+	}
+	return months
+}
+
+func newItem(b structs.Bookmark, tagSlugs map[string]string, root string) item {
+	t := time.Unix(b.Time, 0).UTC()
+
+	it := item{
+		Title:    b.FeedTitle(),
+		URL:      b.URL,
+		Page:     root + articleName(b),
+		Date:     t.Format("2 January 2006"),
+		DateTime: t.Format(time.RFC3339),
+		Excerpt:  b.Summary(),
+	}
+	if u, err := url.Parse(b.URL); err == nil {
+		it.Domain = strings.TrimPrefix(u.Host, "www.")
+	}
+	for _, tag := range b.Tags {
+		it.Tags = append(it.Tags, tagLink{
+			Name: tag,
+			URL:  tagURL(tag, tagSlugs, root),
+		})
+	}
+	return it
+}
+
+func tagURL(tag string, tagSlugs map[string]string, root string) string {
+	return root + "tags/" + tagSlugs[strings.ToLower(tag)] + ".html"
+}
+
+func hasTag(b structs.Bookmark, name string) bool {
+	for _, tag := range b.Tags {
+		if strings.EqualFold(tag, name) {
+			return true
+		}
+	}
+	return false
+}
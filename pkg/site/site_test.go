@@ -0,0 +1,176 @@
+package site
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/chuhlomin/instapaper2rss/pkg/structs"
+)
+
+var bookmarks = []structs.Bookmark{
+	{ID: 1, Time: 1736942400, Title: "January", URL: "https://www.example.com/1", Tags: []string{"Go"}},
+	{ID: 2, Time: 1739202544, Title: "February", URL: "https://example.com/2", Description: "Second <b>post</b>", Tags: []string{"go", "Long reads"}},
+	{
+		ID:       3,
+		Time:     1739300000,
+		Title:    "Latest",
+		URL:      "https://example.com/3",
+		Language: "de",
+		Text:     `<p onclick="x()">Article <em>text</em></p><script>alert(1)</script>`,
+	},
+}
+
+func TestBuilder_BuildFiles(t *testing.T) {
+	files, err := Builder{Title: "Reading", PageSize: 2}.BuildFiles("public", bookmarks)
+	require.NoError(t, err)
+
+	byPath := map[string]string{}
+	var paths []string
+	for _, f := range files {
+		byPath[f.Path] = string(f.Data)
+		paths = append(paths, f.Path)
+	}
+
+	assert.Equal(t, []string{
+		"public/index.html",
+		"public/page-2.html",
+		"public/tags/go.html",
+		"public/tags/long-reads-c3b873ea.html",
+		"public/articles/3.html",
+		"public/articles/2.html",
+		"public/articles/1.html",
+		"public/style.css",
+	}, paths)
+
+	index := byPath["public/index.html"]
+	assert.Contains(t, index, `<title>Reading</title>`)
+	assert.Contains(t, index, `<h2>February 2025</h2>`)
+	assert.Contains(t, index, `<a class="title" href="articles/3.html">Latest</a>`)
+	assert.Contains(t, index, `<a class="title" href="articles/2.html">February</a>`)
+	assert.NotContains(t, index, `articles/1.html`)
+	assert.Contains(t, index, `<p>Second &lt;b&gt;post&lt;/b&gt;</p>`)
+	assert.Contains(t, index, `<li><a href="tags/go.html">go</a></li><li><a href="tags/long-reads-c3b873ea.html">Long reads</a></li>`)
+	assert.Contains(t, index, `<a rel="next" href="page-2.html">Older</a>`)
+	assert.Contains(t, index, `<span>Page 1 of 2</span>`)
+
+	page2 := byPath["public/page-2.html"]
+	assert.Contains(t, page2, `<h2>January 2025</h2>`)
+	assert.Contains(t, page2, `<a rel="prev" href="index.html">Newer</a>`)
+	assert.Contains(t, page2, `example.com · <time datetime="2025-01-15T12:00:00Z">15 January 2025</time>`)
+
+	tag := byPath["public/tags/go.html"]
+	assert.Contains(t, tag, `<h1>go</h1>`, "the latest spelling of a tag is used")
+	assert.Contains(t, tag, `<link rel="stylesheet" href="../style.css">`)
+	assert.Contains(t, tag, `href="../articles/2.html"`)
+	assert.Contains(t, tag, `href="../articles/1.html"`)
+	assert.NotContains(t, tag, `articles/3.html`)
+
+	assert.Contains(t, index, `<html lang="en">`)
+	assert.Contains(t, byPath["public/articles/1.html"], `<html lang="en">`, "without a detected language")
+
+	article := byPath["public/articles/3.html"]
+	assert.Contains(t, article, `<html lang="de">`)
+	assert.Contains(t, article, `<h1>Latest</h1>`)
+	assert.Contains(t, article, `<a href="https://example.com/3">example.com</a>`)
+	assert.Contains(t, article, `<p>Article <em>text</em></p>`)
+	assert.NotContains(t, article, "script")
+	assert.NotContains(t, article, "onclick")
+
+	again, err := Builder{Title: "Reading", PageSize: 2}.BuildFiles("public", bookmarks)
+	require.NoError(t, err)
+	assert.Equal(t, files, again, "unchanged bookmarks render the same files")
+}
+
+func TestBuilder_BuildFilesTemplates(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "article.html"), []byte(`<h1>{{.Item.Title}}</h1>{{.Body}}`), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "style.css"), []byte(`body { color: red; }`), 0o644))
+
+	files, err := Builder{TemplatesDir: dir}.BuildFiles("", bookmarks[2:])
+	require.NoError(t, err)
+
+	byPath := map[string]string{}
+	for _, f := range files {
+		byPath[f.Path] = string(f.Data)
+	}
+
+	assert.Equal(t, `<h1>Latest</h1><p>Article <em>text</em></p>`, byPath["articles/3.html"])
+	assert.Equal(t, `body { color: red; }`, byPath["style.css"])
+	assert.Contains(t, byPath["index.html"], `<title>Instapaper</title>`)
+}
+
+func TestBuilder_BuildEmpty(t *testing.T) {
+	b, err := Builder{}.Build(nil)
+	require.NoError(t, err)
+	assert.Contains(t, string(b), `<h1>Instapaper</h1>`)
+	assert.NotContains(t, string(b), `class="pages"`)
+}
+
+func TestTagSlug(t *testing.T) {
+	tests := map[string]string{
+		"go":         "go",
+		"日本語":        "日本語",
+		"long-reads": "long-reads",
+		"long reads": "long-reads-c3b873ea",
+		"long_reads": "long-reads-cb6baf36",
+		"c++":        "c-cedb1bac",
+		"++":         "cfc0c060",
+	}
+	for tag, expected := range tests {
+		assert.Equal(t, expected, tagSlug(tag), tag)
+	}
+}
+
+func TestCollectTags_Stable(t *testing.T) {
+	_, before := collectTags(bookmarks)
+
+	// a tag with the same slug doesn't rename the existing tag pages
+	_, after := collectTags(append([]structs.Bookmark{
+		{ID: 4, Time: 1739400000, Tags: []string{"long-reads", "GO!"}},
+	}, bookmarks...))
+
+	for tag, slug := range before {
+		assert.Equal(t, slug, after[tag], tag)
+	}
+	assert.Equal(t, "long-reads", after["long-reads"])
+	assert.Regexp(t, `^go-[0-9a-f]{8}$`, after["go!"])
+}
+
+func TestBuilder_Prune(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{
+		"index.html", "page-2.html", "page-2.html.gz", "style.css", "atom.xml",
+		"tags/go.html", "tags/old.html", "tags/old.html.br",
+		"articles/1.html", "articles/2.html", "images/a.png",
+	} {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, nil, 0o644))
+	}
+
+	keep := []string{"index.html", "tags/go.html", "articles/1.html", "style.css"}
+	for i, name := range keep {
+		keep[i] = filepath.Join(dir, name)
+	}
+
+	removed, err := Builder{}.Prune(dir, keep)
+	require.NoError(t, err)
+
+	var names []string
+	for _, path := range removed {
+		name, err := filepath.Rel(dir, path)
+		require.NoError(t, err)
+		names = append(names, filepath.ToSlash(name))
+	}
+	assert.ElementsMatch(t, []string{
+		"page-2.html", "page-2.html.gz", "tags/old.html", "tags/old.html.br", "articles/2.html",
+	}, names)
+
+	for _, name := range []string{"index.html", "style.css", "atom.xml", "tags/go.html", "articles/1.html", "images/a.png"} {
+		assert.FileExists(t, filepath.Join(dir, name))
+	}
+}
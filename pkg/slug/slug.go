@@ -0,0 +1,39 @@
+package slug
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// maxLength limits slugs to keep file names short.
+const maxLength = 80
+
+// Make returns a lowercase file-name-safe version of s:
+// letters and digits are kept, runs of anything else become a dash,
+// e.g. "Go 1.22: What's New?" becomes "go-1-22-what-s-new".
+func Make(s string) string {
+	var sb strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(s) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if dash && sb.Len() > 0 {
+				sb.WriteByte('-')
+			}
+			dash = false
+			sb.WriteRune(r)
+			continue
+		}
+		dash = true
+	}
+
+	result := sb.String()
+	if len(result) > maxLength {
+		result = result[:maxLength]
+		for !utf8.ValidString(result) {
+			result = result[:len(result)-1]
+		}
+		result = strings.TrimRight(result, "-")
+	}
+	return result
+}
@@ -0,0 +1,27 @@
+package slug
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMake(t *testing.T) {
+	tests := map[string]string{
+		"Go 1.22: What's New?":    "go-1-22-what-s-new",
+		"  leading and trailing ": "leading-and-trailing",
+		"Über Café":               "über-café",
+		"★ Starred":               "starred",
+		"---":                     "",
+		"":                        "",
+	}
+
+	for input, expected := range tests {
+		assert.Equal(t, expected, Make(input), input)
+	}
+
+	long := Make(strings.Repeat("word ", 30))
+	assert.LessOrEqual(t, len(long), 80)
+	assert.False(t, strings.HasSuffix(long, "-"))
+}
@@ -1,10 +1,116 @@
 package structs
 
+import (
+	"fmt"
+	"time"
+
+	"github.com/chuhlomin/instapaper2rss/pkg/htmltext"
+)
+
 type Bookmark struct {
-	ID    int
-	Time  int64
-	Title string
-	URL   string
-	Hash  string
-	Text  string
+	ID           int
+	Time         int64 // when the bookmark was saved, Unix seconds
+	Title        string
+	URL          string
+	Hash         string
+	Text         string
+	Description  string
+	Tags         []string
+	Folder       string
+	Starred      bool
+	Progress     float64 // reading progress, 0 to 1
+	ProgressTime int64   // when the progress was last updated, Unix seconds
+	SyncedAt     int64   // when the bookmark was last synced, Unix seconds
+	LegacyID     bool    // published with a numeric feed entry ID before tag URIs
+	Highlights   []Highlight
+	Words        int    // words in the text
+	ReadingTime  int    // estimated reading time, minutes
+	Language     string // ISO 639-1 code of the text, empty when unknown
+	Byline       string // article author, as found in the text
+	Published    int64  // when the article was originally published, Unix seconds
+	SiteName     string // publication name, as found in the text
+	Image        string // URL of the lead image
+}
+
+// Highlight is a passage of the article text highlighted in Instapaper.
+type Highlight struct {
+	ID       int
+	Text     string
+	Time     int64 // when the passage was highlighted, Unix seconds
+	Position int   // order of the highlight in the article
+}
+
+const (
+	// starredPrefix marks titles of starred bookmarks in feeds.
+	starredPrefix = "★ "
+
+	// excerptLength is the maximum length of a summary made from the text.
+	excerptLength = 300
+)
+
+// FeedTitle returns the title to show in feeds,
+// with a marker for starred bookmarks. Bookmarks without
+// a title are shown by URL.
+func (b Bookmark) FeedTitle() string {
+	title := b.Title
+	if title == "" {
+		title = b.URL
+	}
+
+	if b.Starred {
+		return starredPrefix + title
+	}
+	return title
+}
+
+// FeedSummary returns the summary for feed entries,
+// starting with the reading time when it is known.
+func (b Bookmark) FeedSummary() string {
+	summary := b.Summary()
+	if b.ReadingTime == 0 {
+		return summary
+	}
+
+	label := fmt.Sprintf("%d min read", b.ReadingTime)
+	if summary == "" {
+		return label
+	}
+	return label + " · " + summary
+}
+
+// Summary returns the bookmark description, or a plain-text excerpt
+// of the text for bookmarks without one.
+func (b Bookmark) Summary() string {
+	if b.Description != "" {
+		return b.Description
+	}
+	return htmltext.Excerpt(b.Text, excerptLength)
+}
+
+type Run struct {
+	ID               int
+	Command          string // "run", "sync" or "build", empty for runs before they were split
+	Start            time.Time
+	End              time.Time
+	ItemsListed      int
+	TextsFetched     int
+	NewBookmarks     int
+	UpdatedBookmarks int
+	Failures         int
+	Error            string
+	FeedSize         int
+	Files            []string // files changed in this run, unchanged ones are not rewritten
+	FeedChanged      bool     // whether any output file changed
+	Requests         int
+}
+
+// OK reports whether the run finished without failures.
+func (r Run) OK() bool {
+	return r.Failures == 0
+}
+
+// File is a generated output document.
+type File struct {
+	Path string
+	Data []byte
 }
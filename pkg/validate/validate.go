@@ -0,0 +1,336 @@
+package validate
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	atomNamespace = "http://www.w3.org/2005/Atom"
+
+	// maxProblems limits the number of problems reported for one document.
+	maxProblems = 20
+)
+
+// node is an XML element with its attributes, text and children.
+type node struct {
+	name     xml.Name
+	attr     []xml.Attr
+	text     strings.Builder
+	children []*node
+	line     int
+}
+
+func (n *node) attrValue(local string) (string, bool) {
+	for _, a := range n.attr {
+		if a.Name.Local == local && a.Name.Space == "" {
+			return a.Value, true
+		}
+	}
+	return "", false
+}
+
+func (n *node) child(space, local string) *node {
+	for _, c := range n.children {
+		if c.name.Local == local && c.name.Space == space {
+			return c
+		}
+	}
+	return nil
+}
+
+func (n *node) all(space, local string) []*node {
+	var result []*node
+	for _, c := range n.children {
+		if c.name.Local == local && c.name.Space == space {
+			result = append(result, c)
+		}
+	}
+	return result
+}
+
+// Feed checks an Atom (RFC 4287) or RSS 2.0 document:
+// well-formed XML without illegal characters, required elements,
+// valid dates, unique entry IDs and absolute links.
+// All problems found are returned joined in one error.
+func Feed(data []byte) error {
+	return FeedReader(bytes.NewReader(data))
+}
+
+// FeedReader is like Feed, but reads the document from r.
+func FeedReader(r io.Reader) error {
+	root, err := parse(r)
+	if err != nil {
+		return err
+	}
+
+	var p problems
+	switch {
+	case root.name.Space == atomNamespace && root.name.Local == "feed":
+		checkAtom(root, &p)
+	case root.name.Space == "" && root.name.Local == "rss":
+		checkRSS(root, &p)
+	default:
+		return fmt.Errorf("unknown feed type: root element %q", root.name.Local)
+	}
+
+	return p.err()
+}
+
+// parse parses the document into a tree,
+// checking that it is well-formed and has only legal XML characters.
+func parse(r io.Reader) (*node, error) {
+	d := xml.NewDecoder(r)
+	d.Strict = true
+
+	var (
+		root  *node
+		stack []*node
+	)
+
+	for {
+		line, _ := d.InputPos()
+
+		t, err := d.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("malformed XML: %w", err)
+		}
+
+		switch t := t.(type) {
+		case xml.StartElement:
+			n := &node{name: t.Name, attr: t.Attr, line: line}
+			if len(stack) == 0 {
+				if root != nil {
+					return nil, fmt.Errorf("malformed XML: line %d: more than one root element", line)
+				}
+				root = n
+			} else {
+				parent := stack[len(stack)-1]
+				parent.children = append(parent.children, n)
+			}
+			stack = append(stack, n)
+
+		case xml.EndElement:
+			stack = stack[:len(stack)-1]
+
+		case xml.CharData:
+			if len(stack) > 0 {
+				stack[len(stack)-1].text.Write(t)
+			}
+		}
+	}
+
+	if root == nil {
+		return nil, errors.New("malformed XML: no root element")
+	}
+
+	return root, nil
+}
+
+type problems []error
+
+func (p *problems) add(n *node, format string, args ...any) {
+	if len(*p) == maxProblems {
+		*p = append(*p, errors.New("too many problems, stopping"))
+	}
+	if len(*p) > maxProblems {
+		return
+	}
+
+	msg := fmt.Sprintf(format, args...)
+	if n != nil {
+		msg = fmt.Sprintf("line %d: %s", n.line, msg)
+	}
+	*p = append(*p, errors.New(msg))
+}
+
+func (p problems) err() error {
+	return errors.Join(p...)
+}
+
+func checkAtom(feed *node, p *problems) {
+	requireText(feed, atomNamespace, "id", p)
+	requireText(feed, atomNamespace, "title", p)
+	checkAtomDate(feed, "updated", true, p)
+	checkAtomLinks(feed, p)
+
+	for _, local := range []string{"icon", "logo"} {
+		if n := feed.child(atomNamespace, local); n != nil {
+			checkAbsolute(n, local, n.text.String(), p)
+		}
+	}
+
+	feedAuthor := feed.child(atomNamespace, "author") != nil
+	if feedAuthor {
+		requireText(feed.child(atomNamespace, "author"), atomNamespace, "name", p)
+	}
+
+	ids := map[string]int{}
+	for _, entry := range feed.all(atomNamespace, "entry") {
+		if id := requireText(entry, atomNamespace, "id", p); id != "" {
+			if line, ok := ids[id]; ok {
+				p.add(entry, "duplicate entry id %q, first used on line %d", id, line)
+			} else {
+				ids[id] = entry.line
+			}
+		}
+
+		requireText(entry, atomNamespace, "title", p)
+		checkAtomDate(entry, "updated", true, p)
+		checkAtomDate(entry, "published", false, p)
+		checkAtomLinks(entry, p)
+
+		if !feedAuthor && entry.child(atomNamespace, "author") == nil {
+			p.add(entry, "entry has no author and the feed has no author")
+		}
+
+		// RFC 4287 4.1.1.1: without content, an entry needs an alternate link
+		if entry.child(atomNamespace, "content") == nil && !hasAlternate(entry) {
+			p.add(entry, "entry has neither content nor a link with rel=\"alternate\"")
+		}
+	}
+}
+
+func checkAtomDate(n *node, local string, required bool, p *problems) {
+	d := n.child(atomNamespace, local)
+	if d == nil {
+		if required {
+			p.add(n, "<%s> is missing <%s>", n.name.Local, local)
+		}
+		return
+	}
+
+	if _, err := time.Parse(time.RFC3339, strings.TrimSpace(d.text.String())); err != nil {
+		p.add(d, "<%s> is not an RFC 3339 date: %q", local, d.text.String())
+	}
+}
+
+func checkAtomLinks(n *node, p *problems) {
+	for _, link := range n.all(atomNamespace, "link") {
+		href, ok := link.attrValue("href")
+		if !ok {
+			p.add(link, "<link> has no href")
+			continue
+		}
+		checkAbsolute(link, "link href", href, p)
+	}
+}
+
+func hasAlternate(entry *node) bool {
+	for _, link := range entry.all(atomNamespace, "link") {
+		if rel, ok := link.attrValue("rel"); !ok || rel == "alternate" {
+			return true
+		}
+	}
+	return false
+}
+
+func checkRSS(rss *node, p *problems) {
+	if v, _ := rss.attrValue("version"); v != "2.0" {
+		p.add(rss, "unsupported RSS version %q", v)
+	}
+
+	channel := rss.child("", "channel")
+	if channel == nil {
+		p.add(rss, "<rss> is missing <channel>")
+		return
+	}
+
+	requireText(channel, "", "title", p)
+	if link := requireText(channel, "", "link", p); link != "" {
+		checkAbsolute(channel.child("", "link"), "channel link", link, p)
+	}
+	if channel.child("", "description") == nil {
+		p.add(channel, "<channel> is missing <description>")
+	}
+	checkAtomLinks(channel, p)
+
+	for _, local := range []string{"pubDate", "lastBuildDate"} {
+		checkRSSDate(channel, local, p)
+	}
+
+	guids := map[string]int{}
+	for _, item := range channel.all("", "item") {
+		if item.child("", "title") == nil && item.child("", "description") == nil {
+			p.add(item, "<item> has neither <title> nor <description>")
+		}
+
+		if link := item.child("", "link"); link != nil {
+			checkAbsolute(link, "item link", link.text.String(), p)
+		}
+
+		if guid := item.child("", "guid"); guid != nil {
+			value := strings.TrimSpace(guid.text.String())
+			if line, ok := guids[value]; ok {
+				p.add(guid, "duplicate guid %q, first used on line %d", value, line)
+			} else {
+				guids[value] = guid.line
+			}
+
+			if isPermaLink, _ := guid.attrValue("isPermaLink"); isPermaLink != "false" {
+				checkAbsolute(guid, "permalink guid", value, p)
+			}
+		}
+
+		checkRSSDate(item, "pubDate", p)
+	}
+}
+
+var rssDateLayouts = []string{
+	time.RFC1123Z,
+	time.RFC1123,
+	time.RFC822Z,
+	time.RFC822,
+}
+
+func checkRSSDate(n *node, local string, p *problems) {
+	d := n.child("", local)
+	if d == nil {
+		return
+	}
+
+	value := strings.TrimSpace(d.text.String())
+	for _, layout := range rssDateLayouts {
+		if _, err := time.Parse(layout, value); err == nil {
+			return
+		}
+	}
+	p.add(d, "<%s> is not an RFC 822 date: %q", local, value)
+}
+
+// requireText reports a problem if the child element is missing or empty,
+// and returns its trimmed text.
+func requireText(n *node, space, local string, p *problems) string {
+	c := n.child(space, local)
+	if c == nil {
+		p.add(n, "<%s> is missing <%s>", n.name.Local, local)
+		return ""
+	}
+
+	text := strings.TrimSpace(c.text.String())
+	if text == "" && len(c.children) == 0 {
+		p.add(c, "<%s> is empty", local)
+	}
+	return text
+}
+
+func checkAbsolute(n *node, what, href string, p *problems) {
+	u, err := url.Parse(strings.TrimSpace(href))
+	if err != nil {
+		p.add(n, "%s %q is not a valid URL: %v", what, href, err)
+		return
+	}
+
+	if !u.IsAbs() || u.Host == "" {
+		p.add(n, "%s %q is not an absolute URL", what, href)
+	}
+}
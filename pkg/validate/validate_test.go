@@ -0,0 +1,116 @@
+package validate_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/chuhlomin/instapaper2rss/pkg/atom"
+	"github.com/chuhlomin/instapaper2rss/pkg/rss"
+	"github.com/chuhlomin/instapaper2rss/pkg/structs"
+	"github.com/chuhlomin/instapaper2rss/pkg/validate"
+)
+
+var bookmarks = []structs.Bookmark{
+	{ID: 1, Time: 1739202544, Title: "First", URL: "https://example.com/1", Text: "<p>One</p>"},
+	{ID: 2, Time: 1739202545, Title: "Second", URL: "https://example.com/2", Text: "<p>Two<br></p>", Tags: []string{"go"}},
+}
+
+func TestFeed_Builders(t *testing.T) {
+	b, err := atom.FeedBuilder{SelfURL: "https://example.com/atom.xml"}.Build(bookmarks)
+	require.NoError(t, err)
+	assert.NoError(t, validate.Feed(b))
+
+	b, err = rss.FeedBuilder{SelfURL: "https://example.com/rss.xml"}.Build(bookmarks)
+	require.NoError(t, err)
+	assert.NoError(t, validate.Feed(b))
+}
+
+func TestFeed_Problems(t *testing.T) {
+	tests := []struct {
+		name string
+		feed string
+		want []string
+	}{
+		{
+			name: "malformed",
+			feed: `<feed xmlns="http://www.w3.org/2005/Atom"><title>Unclosed</feed>`,
+			want: []string{"malformed XML"},
+		},
+		{
+			name: "illegal character",
+			feed: "<feed xmlns=\"http://www.w3.org/2005/Atom\"><title>Vertical\x0Btab</title></feed>",
+			want: []string{"malformed XML", "illegal character code U+000B"},
+		},
+		{
+			name: "unknown root",
+			feed: `<html></html>`,
+			want: []string{`unknown feed type: root element "html"`},
+		},
+		{
+			name: "atom",
+			feed: `<feed xmlns="http://www.w3.org/2005/Atom">
+  <title>Feed</title>
+  <updated>yesterday</updated>
+  <link rel="self" href="/atom.xml"/>
+  <entry>
+    <id>1</id>
+    <title>One</title>
+    <updated>2025-02-10T15:49:04Z</updated>
+    <link href="https://example.com/1"/>
+  </entry>
+  <entry>
+    <id>1</id>
+    <title></title>
+    <updated>2025-02-10T15:49:04Z</updated>
+    <content type="html">Two</content>
+  </entry>
+</feed>`,
+			want: []string{
+				"line 1: <feed> is missing <id>",
+				`line 3: <updated> is not an RFC 3339 date: "yesterday"`,
+				`line 4: link href "/atom.xml" is not an absolute URL`,
+				"line 5: entry has no author and the feed has no author",
+				`line 11: duplicate entry id "1", first used on line 5`,
+				"line 13: <title> is empty",
+				"line 11: entry has no author and the feed has no author",
+			},
+		},
+		{
+			name: "rss",
+			feed: `<rss version="2.0">
+  <channel>
+    <title>Feed</title>
+    <link>example.com</link>
+    <item>
+      <link>https://example.com/1</link>
+      <guid isPermaLink="false">1</guid>
+      <pubDate>2025-02-10</pubDate>
+    </item>
+    <item>
+      <title>Two</title>
+      <guid isPermaLink="false">1</guid>
+    </item>
+  </channel>
+</rss>`,
+			want: []string{
+				`line 4: channel link "example.com" is not an absolute URL`,
+				"line 2: <channel> is missing <description>",
+				"line 5: <item> has neither <title> nor <description>",
+				`line 8: <pubDate> is not an RFC 822 date: "2025-02-10"`,
+				`line 12: duplicate guid "1", first used on line 7`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validate.Feed([]byte(tt.feed))
+			require.Error(t, err)
+			for _, want := range tt.want {
+				assert.Contains(t, err.Error(), want)
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package xmltext
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/chuhlomin/instapaper2rss/pkg/structs"
+)
+
+// IsChar reports whether r is allowed in XML 1.0 documents:
+// #x9 | #xA | #xD | [#x20-#xD7FF] | [#xE000-#xFFFD] | [#x10000-#x10FFFF].
+func IsChar(r rune) bool {
+	return r == 0x09 || r == 0x0A || r == 0x0D ||
+		r >= 0x20 && r <= 0xD7FF ||
+		r >= 0xE000 && r <= 0xFFFD ||
+		r >= 0x10000 && r <= 0x10FFFF
+}
+
+// Clean makes s safe to embed in XML: invalid UTF-8 sequences
+// are replaced with U+FFFD and characters not allowed in XML 1.0,
+// such as control characters, are removed.
+func Clean(s string) string {
+	if isClean(s) {
+		return s
+	}
+
+	var sb strings.Builder
+	sb.Grow(len(s))
+
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		i += size
+
+		switch {
+		case r == utf8.RuneError && size == 1:
+			sb.WriteRune(utf8.RuneError)
+		case IsChar(r):
+			sb.WriteRune(r)
+		}
+	}
+
+	return sb.String()
+}
+
+func isClean(s string) bool {
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size == 1 || !IsChar(r) {
+			return false
+		}
+		i += size
+	}
+	return true
+}
+
+// CleanAll cleans every string in ss, returning a new slice.
+func CleanAll(ss []string) []string {
+	if ss == nil {
+		return nil
+	}
+
+	result := make([]string, len(ss))
+	for i, s := range ss {
+		result[i] = Clean(s)
+	}
+	return result
+}
+
+// Bookmark returns a copy of b with all text fields cleaned.
+func Bookmark(b structs.Bookmark) structs.Bookmark {
+	b.Title = Clean(b.Title)
+	b.URL = Clean(b.URL)
+	b.Text = Clean(b.Text)
+	b.Description = Clean(b.Description)
+	b.Folder = Clean(b.Folder)
+	b.Tags = CleanAll(b.Tags)
+	b.Byline = Clean(b.Byline)
+	b.SiteName = Clean(b.SiteName)
+	b.Image = Clean(b.Image)
+	return b
+}
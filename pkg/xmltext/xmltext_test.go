@@ -0,0 +1,91 @@
+package xmltext
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/chuhlomin/instapaper2rss/pkg/structs"
+)
+
+func TestClean(t *testing.T) {
+	expected := map[string]string{
+		"control":       "Verticaltab and unitseparator and NUL end",
+		"invalid-utf8":  "Latin-1 caf� and truncated ��",
+		"surrogate":     "Lone surrogate ��� here",
+		"noncharacters": "Byte order  and  swapped",
+		"allowed":       "Tab\tnewline\\n emoji 📚 and ★",
+		"html":          "<p onclick=\"x\">Paragraph with <b>bold\x7f</b></p>",
+	}
+
+	for name, input := range readFixtures(t) {
+		t.Run(name, func(t *testing.T) {
+			got := Clean(input)
+			assert.Equal(t, expected[name], got)
+			assert.True(t, utf8.ValidString(got))
+			assertWellFormed(t, got)
+		})
+	}
+}
+
+func TestClean_Unchanged(t *testing.T) {
+	s := "Plain <p>text</p>\r\n"
+	assert.Equal(t, s, Clean(s))
+}
+
+func TestBookmark(t *testing.T) {
+	b := Bookmark(structs.Bookmark{
+		ID:          1,
+		Title:       "Title\x00",
+		URL:         "https://example.com/\x1b",
+		Text:        "<p>\xff</p>",
+		Description: "Desc\x0c",
+		Folder:      "Folder\x01",
+		Tags:        []string{"go\x02", "ok"},
+	})
+
+	assert.Equal(t, structs.Bookmark{
+		ID:          1,
+		Title:       "Title",
+		URL:         "https://example.com/",
+		Text:        "<p>�</p>",
+		Description: "Desc",
+		Folder:      "Folder",
+		Tags:        []string{"go", "ok"},
+	}, b)
+}
+
+func readFixtures(t *testing.T) map[string]string {
+	t.Helper()
+
+	data, err := os.ReadFile("testdata/nasty.txt")
+	require.NoError(t, err)
+
+	fixtures := map[string]string{}
+	for _, line := range bytes.Split(bytes.TrimSuffix(data, []byte("\n")), []byte("\n")) {
+		name, input, ok := bytes.Cut(line, []byte("\t"))
+		require.True(t, ok, "malformed fixture line %q", line)
+		fixtures[string(name)] = string(input)
+	}
+	return fixtures
+}
+
+func assertWellFormed(t *testing.T, s string) {
+	t.Helper()
+
+	d := xml.NewDecoder(strings.NewReader("<x><![CDATA[" + s + "]]></x>"))
+	for {
+		_, err := d.Token()
+		if err == io.EOF {
+			return
+		}
+		require.NoError(t, err)
+	}
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/chuhlomin/instapaper2rss/pkg/analyze"
+	"github.com/chuhlomin/instapaper2rss/pkg/extract"
+	"github.com/chuhlomin/instapaper2rss/pkg/images"
+	"github.com/chuhlomin/instapaper2rss/pkg/resolve"
+	"github.com/chuhlomin/instapaper2rss/pkg/sanitize"
+	"github.com/chuhlomin/instapaper2rss/pkg/structs"
+)
+
+// metadataExtractor saves the byline, publish date, site name
+// and lead image found in article text on the bookmark.
+type metadataExtractor struct{}
+
+func (metadataExtractor) Process(b *structs.Bookmark) error {
+	if err := extract.Bookmark(b); err != nil {
+		return fmt.Errorf("error extracting metadata: %w", err)
+	}
+	return nil
+}
+
+// urlResolver makes relative URLs in article text absolute,
+// using the bookmark URL as the base.
+type urlResolver struct{}
+
+func (urlResolver) Process(b *structs.Bookmark) error {
+	if b.URL == "" || b.Text == "" {
+		return nil
+	}
+
+	text, err := resolve.URLs(b.Text, b.URL)
+	if err != nil {
+		return fmt.Errorf("error resolving URLs: %w", err)
+	}
+
+	b.Text = text
+	return nil
+}
+
+// sanitizer removes markup the policy does not allow from article text.
+type sanitizer struct {
+	policy sanitize.Policy
+}
+
+func (s sanitizer) Process(b *structs.Bookmark) error {
+	text, report, err := s.policy.Sanitize(b.Text)
+	if err != nil {
+		return fmt.Errorf("error sanitizing text: %w", err)
+	}
+
+	if !report.Empty() {
+		log.Printf("Bookmark %d: removed %s", b.ID, report)
+	}
+
+	b.Text = text
+	return nil
+}
+
+// imageArchiver downloads article images and the lead image
+// to be published next to the feed.
+type imageArchiver struct {
+	archiver *images.Archiver
+}
+
+func (p imageArchiver) Process(b *structs.Bookmark) error {
+	text, err := p.archiver.Archive(b.Text)
+	if err != nil {
+		return fmt.Errorf("error archiving images: %w", err)
+	}
+
+	b.Text = text
+	// the lead image is often in the text too, and downloaded once
+	if b.Image != "" {
+		b.Image = p.archiver.ArchiveURL(b.Image)
+	}
+	return nil
+}
+
+func (p imageArchiver) Files() []string {
+	return p.archiver.Files()
+}
+
+// analyzer counts words, estimates the reading time
+// and detects the language of article text.
+type analyzer struct{}
+
+func (analyzer) Process(b *structs.Bookmark) error {
+	analyze.Bookmark(b)
+	return nil
+}
+
+// createProcessors returns the processors applied to new bookmarks,
+// configured with environment variables.
+func createProcessors() ([]Processor, error) {
+	// extract metadata from the original markup, then resolve URLs
+	// before the sanitizer drops the lazy-loading attributes
+	processors := []Processor{metadataExtractor{}, urlResolver{}}
+
+	if name := getEnvVar("SANITIZE_POLICY", "reader-friendly"); name != "none" {
+		policy, err := sanitize.Named(name)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := allowElements(policy, getEnvVar("SANITIZE_ALLOW", "")); err != nil {
+			return nil, err
+		}
+
+		processors = append(processors, sanitizer{policy: policy})
+	}
+
+	// archive images last, after the sanitizer removed tracking pixels
+	if dir := getEnvVar("IMAGES_DIR", ""); dir != "" {
+		archiver, err := createImageArchiver(dir)
+		if err != nil {
+			return nil, err
+		}
+		processors = append(processors, imageArchiver{archiver: archiver})
+	}
+
+	// analyze the text as it is published
+	processors = append(processors, analyzer{})
+
+	return processors, nil
+}
+
+func createImageArchiver(dir string) (*images.Archiver, error) {
+	baseURL := imagesURL(dir)
+	if baseURL == "" {
+		return nil, fmt.Errorf("IMAGES_URL or FEED_URL is required with IMAGES_DIR")
+	}
+
+	var maxSize int64 = images.DefaultMaxSize
+	if s := getEnvVar("IMAGES_MAX_SIZE", ""); s != "" {
+		var err error
+		maxSize, err = strconv.ParseInt(s, 10, 64)
+		if err != nil || maxSize <= 0 {
+			return nil, fmt.Errorf("invalid IMAGES_MAX_SIZE %q", s)
+		}
+	}
+
+	return &images.Archiver{
+		Dir:     dir,
+		BaseURL: baseURL,
+		MaxSize: maxSize,
+	}, nil
+}
+
+// imagesURL returns the public URL of the images directory:
+// IMAGES_URL, or the directory's name next to FEED_URL.
+func imagesURL(dir string) string {
+	return getEnvVar("IMAGES_URL", siblingURL(getEnvVar("FEED_URL", ""), dir))
+}
+
+// allowElements adds elements to the policy from a space-separated list
+// of "element" or "element[attr,attr]" entries, e.g. "video[src,controls] kbd".
+func allowElements(policy sanitize.Policy, spec string) error {
+	for _, entry := range strings.Fields(spec) {
+		element, attrs, hasAttrs := strings.Cut(entry, "[")
+		if element == "" {
+			return fmt.Errorf("invalid SANITIZE_ALLOW entry %q", entry)
+		}
+
+		if !hasAttrs {
+			policy.Allow(element)
+			continue
+		}
+
+		attrs, ok := strings.CutSuffix(attrs, "]")
+		if !ok {
+			return fmt.Errorf("invalid SANITIZE_ALLOW entry %q: missing \"]\"", entry)
+		}
+		policy.Allow(element, strings.Split(attrs, ",")...)
+	}
+
+	return nil
+}
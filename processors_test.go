@@ -0,0 +1,67 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/chuhlomin/instapaper2rss/pkg/images"
+	"github.com/chuhlomin/instapaper2rss/pkg/sanitize"
+	"github.com/chuhlomin/instapaper2rss/pkg/structs"
+)
+
+func TestAllowElements(t *testing.T) {
+	policy := sanitize.Strict()
+	require.NoError(t, allowElements(policy, "video[src,controls] kbd"))
+	assert.Equal(t, []string{"src", "controls"}, policy.Elements["video"])
+	assert.Contains(t, policy.Elements, "kbd")
+
+	assert.EqualError(t, allowElements(policy, "video[src"), `invalid SANITIZE_ALLOW entry "video[src": missing "]"`)
+	assert.EqualError(t, allowElements(policy, "[src]"), `invalid SANITIZE_ALLOW entry "[src]"`)
+}
+
+func TestProcessors(t *testing.T) {
+	t.Setenv("SANITIZE_POLICY", "reader-friendly")
+
+	processors, err := createProcessors()
+	require.NoError(t, err)
+
+	b := structs.Bookmark{
+		ID:   1,
+		URL:  "https://example.com/posts/1",
+		Text: `<p><a href="../about" class="link">About</a><img data-src="photo.jpg" src="data:image/gif;base64,R0lGOD"></p>`,
+	}
+	for _, p := range processors {
+		require.NoError(t, p.Process(&b))
+	}
+
+	assert.Equal(t, `<p><a href="https://example.com/about">About</a><img src="https://example.com/posts/photo.jpg"/></p>`, b.Text)
+	assert.Equal(t, 1, b.Words)
+	assert.Equal(t, 1, b.ReadingTime)
+}
+
+func TestCreateImageArchiver(t *testing.T) {
+	t.Setenv("FEED_URL", "https://example.com/feeds/atom.xml")
+
+	archiver, err := createImageArchiver("public/images")
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/feeds/images", archiver.BaseURL)
+	assert.Equal(t, int64(images.DefaultMaxSize), archiver.MaxSize)
+
+	t.Setenv("IMAGES_URL", "https://cdn.example.com/i/")
+	t.Setenv("IMAGES_MAX_SIZE", "1024")
+	archiver, err = createImageArchiver("public/images")
+	require.NoError(t, err)
+	assert.Equal(t, "https://cdn.example.com/i/", archiver.BaseURL)
+	assert.Equal(t, int64(1024), archiver.MaxSize)
+
+	t.Setenv("IMAGES_MAX_SIZE", "big")
+	_, err = createImageArchiver("public/images")
+	assert.EqualError(t, err, `invalid IMAGES_MAX_SIZE "big"`)
+
+	t.Setenv("IMAGES_URL", "")
+	t.Setenv("FEED_URL", "")
+	_, err = createImageArchiver("public/images")
+	assert.EqualError(t, err, "IMAGES_URL or FEED_URL is required with IMAGES_DIR")
+}